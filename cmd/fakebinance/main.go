@@ -0,0 +1,196 @@
+// Command fakebinance serves canned Binance spot and futures responses and injects
+// failures, so that retries and the circuit breaker can be exercised locally:
+//
+//	go run ./cmd/fakebinance -addr :9300 -error-rate 0.5 -reset-rate 0.1
+//	SPOT_BASE_URL=http://localhost:9300 FUTURES_BASE_URL=http://localhost:9300 go run .
+//
+// The failure rates can be changed while running, e.g.
+// curl -X POST 'localhost:9300/fake/faults?errorRate=1&resetRate=0&latency=0s'.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// faults holds the failures injected into every upstream call.
+type faults struct {
+	mu        sync.Mutex
+	errorRate float64
+	resetRate float64
+	latency   time.Duration
+	clockSkew time.Duration
+	calls     uint64
+	injected  uint64
+}
+
+func main() {
+	addr := flag.String("addr", ":9300", "listen address")
+	errorRate := flag.Float64("error-rate", 0, "fraction of calls answered with 503")
+	resetRate := flag.Float64("reset-rate", 0, "fraction of calls whose connection is reset")
+	latency := flag.Duration("latency", 0, "delay added to every call")
+	clockSkew := flag.Duration("clock-skew", 0, "offset added to the reported exchange time")
+	flag.Parse()
+
+	f := &faults{errorRate: *errorRate, resetRate: *resetRate, latency: *latency, clockSkew: *clockSkew}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fake/faults", f.handleFaults)
+	mux.HandleFunc("/", f.handleUpstream)
+
+	log.Printf("fake binance listening on %s (error rate %.2f, reset rate %.2f, latency %s)", *addr, *errorRate, *resetRate, *latency)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// handleFaults reports the injected faults and updates them from the query on POST.
+func (f *faults) handleFaults(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method == http.MethodPost {
+		q := r.URL.Query()
+		if v, err := strconv.ParseFloat(q.Get("errorRate"), 64); err == nil {
+			f.errorRate = v
+		}
+		if v, err := strconv.ParseFloat(q.Get("resetRate"), 64); err == nil {
+			f.resetRate = v
+		}
+		if v, err := time.ParseDuration(q.Get("latency")); err == nil {
+			f.latency = v
+		}
+		if v, err := time.ParseDuration(q.Get("clockSkew")); err == nil {
+			f.clockSkew = v
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errorRate": f.errorRate,
+		"resetRate": f.resetRate,
+		"latency":   f.latency.String(),
+		"clockSkew": f.clockSkew.String(),
+		"calls":     f.calls,
+		"injected":  f.injected,
+	})
+}
+
+func (f *faults) handleUpstream(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.calls++
+	latency := f.latency
+	clockSkew := f.clockSkew
+	roll := rand.Float64()
+	reset := roll < f.resetRate
+	fail := !reset && roll < f.resetRate+f.errorRate
+	if reset || fail {
+		f.injected++
+	}
+	f.mu.Unlock()
+
+	time.Sleep(latency)
+	switch {
+	case reset:
+		resetConnection(w)
+		return
+	case fail:
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"code": -1001, "msg": "Internal error; unable to process your request. Please try again."})
+		return
+	}
+
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v3"), "/fapi/v1")
+	body, ok := cannedResponse(path, r.URL.Query().Get("symbol"), clockSkew)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"code": -1100, "msg": "Unknown endpoint " + r.URL.Path})
+		return
+	}
+	w.Header().Set("X-MBX-USED-WEIGHT-1M", "1")
+	writeJSON(w, http.StatusOK, body)
+}
+
+// resetConnection closes the client connection without writing a response.
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	conn.Close()
+}
+
+// cannedResponse returns a fixed body for the endpoints used by the server. Like
+// Binance, the ticker and premium index endpoints list every symbol, here just one,
+// when no symbol is given.
+func cannedResponse(path, symbol string, clockSkew time.Duration) (interface{}, bool) {
+	allSymbols := symbol == ""
+	if allSymbols {
+		symbol = "BTCUSDT"
+	}
+	perSymbol := func(body interface{}) interface{} {
+		if allSymbols {
+			return []interface{}{body}
+		}
+		return body
+	}
+	now := time.Now().Add(clockSkew).UnixMilli()
+	ticker := map[string]interface{}{"symbol": symbol, "price": "65000.00", "time": now}
+	bookTicker := map[string]interface{}{"symbol": symbol, "bidPrice": "64999.99", "bidQty": "1.5", "askPrice": "65000.01", "askQty": "2.0"}
+	ticker24h := map[string]interface{}{
+		"symbol": symbol, "priceChange": "100.00", "priceChangePercent": "0.15", "weightedAvgPrice": "64900.00",
+		"lastPrice": "65000.00", "lastQty": "0.01", "openPrice": "64900.00", "highPrice": "65500.00", "lowPrice": "64000.00",
+		"volume": "12000.5", "quoteVolume": "780000000.0", "openTime": now - 86400000, "closeTime": now, "count": 1000000,
+	}
+	trade := map[string]interface{}{"id": 1, "price": "65000.00", "qty": "0.01", "quoteQty": "650.00", "time": now, "isBuyerMaker": true}
+	aggTrade := map[string]interface{}{"a": 1, "p": "65000.00", "q": "0.01", "f": 1, "l": 1, "T": now, "m": true}
+	kline := []interface{}{now - 60000, "64990.00", "65010.00", "64980.00", "65000.00", "12.5", now - 1, "812500.00", 100, "6.0", "390000.00", "0"}
+
+	switch path {
+	case "/ping":
+		return map[string]interface{}{}, true
+	case "/time":
+		return map[string]interface{}{"serverTime": now}, true
+	case "/exchangeInfo":
+		return map[string]interface{}{
+			"timezone": "UTC", "serverTime": now, "rateLimits": []interface{}{},
+			"symbols": []interface{}{map[string]interface{}{"symbol": symbol, "status": "TRADING", "baseAsset": "BTC", "quoteAsset": "USDT", "filters": []interface{}{}}},
+		}, true
+	case "/ticker/price":
+		return perSymbol(ticker), true
+	case "/ticker/bookTicker":
+		return perSymbol(bookTicker), true
+	case "/ticker/24hr":
+		return perSymbol(ticker24h), true
+	case "/depth":
+		return map[string]interface{}{"lastUpdateId": 1, "bids": [][]string{{"64999.99", "1.5"}}, "asks": [][]string{{"65000.01", "2.0"}}}, true
+	case "/trades", "/historicalTrades":
+		return []interface{}{trade}, true
+	case "/aggTrades":
+		return []interface{}{aggTrade}, true
+	case "/klines":
+		return []interface{}{kline}, true
+	case "/avgPrice":
+		return map[string]interface{}{"mins": 5, "price": "65000.00", "closeTime": now}, true
+	case "/premiumIndex":
+		return perSymbol(map[string]interface{}{"symbol": symbol, "markPrice": "65000.00", "indexPrice": "64998.00", "estimatedSettlePrice": "64999.00", "lastFundingRate": "0.0001", "interestRate": "0.0001", "nextFundingTime": now + 3600000, "time": now}), true
+	case "/fundingRate":
+		return []interface{}{map[string]interface{}{"symbol": symbol, "fundingRate": "0.0001", "fundingTime": now, "markPrice": "65000.00"}}, true
+	case "/allForceOrders":
+		return []interface{}{}, true
+	default:
+		return nil, false
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
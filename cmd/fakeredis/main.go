@@ -0,0 +1,47 @@
+// Command fakeredis runs an in-process Redis stand-in, so that the redis and tiered
+// cache backends can be tried without a Redis server:
+//
+//	go run ./cmd/fakeredis -addr :6379
+//	CACHE_BACKEND=tiered REDIS_ADDR=localhost:6379 go run .
+//
+// Data is kept in memory and lost when the process exits.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func main() {
+	addr := flag.String("addr", ":6379", "listen address")
+	flag.Parse()
+
+	server := miniredis.NewMiniRedis()
+	if err := server.StartAddr(*addr); err != nil {
+		log.Fatalf("error starting fake redis: %v", err)
+	}
+	defer server.Close()
+	log.Printf("fake redis listening on %s", server.Addr())
+
+	// miniredis only expires keys when told that time has passed.
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	last := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			server.FastForward(now.Sub(last))
+			last = now
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// CachePolicy overrides the cache behaviour of one cache name ("depth", "klines",
+// "exchangeinfo", ...). Zero fields fall back to the upstream's CacheTTL and CacheDelay.
+type CachePolicy struct {
+	// TTL is how long a fetched response is kept in the cache.
+	TTL Duration `json:"ttl"`
+	// RefreshInterval is the minimum time between two upstream refreshes of the same key.
+	RefreshInterval Duration `json:"refreshInterval"`
+	// StaleWhileRevalidate serves the cached value and refreshes it in the background on
+	// every hit once RefreshInterval has passed. When false, an entry is only refetched
+	// after it expires. Defaults to true.
+	StaleWhileRevalidate *bool `json:"staleWhileRevalidate,omitempty"`
+}
+
+// defaultCachePolicies returns the policy table shared by the spot and futures defaults.
+// Order book and ticker data follow the upstream defaults; reference data that changes
+// slowly is kept much longer.
+func defaultCachePolicies() map[string]CachePolicy {
+	return map[string]CachePolicy{
+		"exchangeinfo": {TTL: Duration(1 * time.Hour), RefreshInterval: Duration(5 * time.Minute)},
+		"fundingrate":  {TTL: Duration(10 * time.Minute), RefreshInterval: Duration(1 * time.Minute)},
+		"klines":       {TTL: Duration(1 * time.Minute), RefreshInterval: Duration(1 * time.Second)},
+		"avgprice":     {TTL: Duration(1 * time.Minute), RefreshInterval: Duration(1 * time.Second)},
+		"markprice":    {TTL: Duration(1 * time.Minute), RefreshInterval: Duration(1 * time.Second)},
+	}
+}
+
+// defaultEndpointTimeouts gives the large reference-data responses more time than
+// the default request timeout.
+func defaultEndpointTimeouts() map[string]Duration {
+	return map[string]Duration{
+		"exchangeinfo":    Duration(20 * time.Second),
+		"alltickerprices": Duration(15 * time.Second),
+		"allticker24hr":   Duration(15 * time.Second),
+	}
+}
+
+// validate checks p against the upstream's ttl and delay, which its zero fields fall
+// back to, so that the refresh interval never exceeds the TTL it ends up with.
+func (p CachePolicy) validate(name string, ttl, delay Duration) []error {
+	var errs []error
+	if p.TTL < 0 {
+		errs = append(errs, fmt.Errorf("%s.ttl must not be negative", name))
+	}
+	if p.RefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("%s.refreshInterval must not be negative", name))
+	}
+	if p.TTL <= 0 && p.RefreshInterval <= 0 {
+		// The upstream's own values apply, which are checked with it.
+		return errs
+	}
+	if p.TTL > 0 {
+		ttl = p.TTL
+	}
+	if p.RefreshInterval > 0 {
+		delay = p.RefreshInterval
+	}
+	if delay > ttl {
+		errs = append(errs, fmt.Errorf("%s: the refresh interval %s must not exceed the TTL %s", name, delay.Duration(), ttl.Duration()))
+	}
+	return errs
+}
@@ -0,0 +1,753 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config is the top-level application configuration.
+type Config struct {
+	Server  ServerConfig   `json:"server"`
+	Spot    UpstreamConfig `json:"spot"`
+	Futures UpstreamConfig `json:"futures"`
+	Cache   CacheConfig    `json:"cache"`
+	HTTP    HTTPConfig     `json:"http"`
+	Admin   AdminConfig    `json:"admin"`
+	Warm    WarmConfig     `json:"warm"`
+	Clock   ClockConfig    `json:"clock"`
+	Health  HealthConfig   `json:"health"`
+	Tracing TracingConfig  `json:"tracing"`
+	Log     LogConfig      `json:"log"`
+	Auth    AuthConfig     `json:"auth"`
+	CORS    CORSConfig     `json:"cors"`
+}
+
+// CORSConfig configures which browser origins may call the API.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the API, each either exact, e.g.
+	// "https://app.example.com", a wildcard subdomain, e.g. "https://*.example.com", which
+	// matches subdomains at any depth but not example.com itself, or "*" for any origin.
+	AllowedOrigins []string `json:"allowedOrigins"`
+	// AllowedMethods and AllowedHeaders are answered to preflight requests.
+	AllowedMethods []string `json:"allowedMethods"`
+	AllowedHeaders []string `json:"allowedHeaders"`
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders []string `json:"exposedHeaders"`
+	// AllowCredentials lets browsers send cookies and Authorization headers. It needs
+	// the origins to be listed, as browsers refuse credentials with "*".
+	AllowCredentials bool `json:"allowCredentials"`
+	// MaxAge is how long browsers may cache a preflight response. Zero leaves it to the
+	// browser.
+	MaxAge Duration `json:"maxAge"`
+}
+
+// AuthConfig configures API-key authentication and per-client rate limiting of the
+// Binance data routes.
+type AuthConfig struct {
+	// Enabled turns authentication on.
+	Enabled bool `json:"enabled"`
+	// Header is the request header carrying the API key.
+	Header string `json:"header"`
+	// Keys lists the accepted API keys.
+	Keys []APIKey `json:"keys"`
+	// KeysFile is an optional JSON file holding more keys as an array of the same
+	// objects. It is read on load and its keys are added to Keys, so that keys can be
+	// kept out of the main config.
+	KeysFile string `json:"keysFile"`
+	// Tiers are the rate limits keys are assigned to, by name.
+	Tiers map[string]RateTier `json:"tiers"`
+	// AnonymousTier is the tier of requests without a key, limited per client IP.
+	// Empty rejects them.
+	AnonymousTier string `json:"anonymousTier"`
+}
+
+// APIKey is one accepted API key.
+type APIKey struct {
+	Key string `json:"key"`
+	// Name identifies the key holder in the usage report, which never shows the key
+	// itself. It defaults to the first characters of the key.
+	Name string `json:"name"`
+	Tier string `json:"tier"`
+}
+
+// ClientName returns Name, or the first characters of the key if it has no name.
+func (k APIKey) ClientName() string {
+	const shown = 4
+	switch {
+	case k.Name != "":
+		return k.Name
+	case len(k.Key) <= shown:
+		return "…"
+	default:
+		return k.Key[:shown] + "…"
+	}
+}
+
+// RateTier limits the requests of one client with a token bucket and a daily quota.
+type RateTier struct {
+	// RatePerSecond is the rate at which the bucket refills.
+	RatePerSecond float64 `json:"ratePerSecond"`
+	// Burst is the bucket size, i.e. the most requests allowed at once.
+	Burst int `json:"burst"`
+	// DailyQuota is the number of requests allowed per UTC day. Zero is unlimited.
+	DailyQuota int `json:"dailyQuota"`
+}
+
+// LogConfig configures the structured logger.
+type LogConfig struct {
+	// Format is "text" (key=value pairs) or "json".
+	Format string `json:"format"`
+	// Level is the lowest level written: "debug", "info", "warn" or "error".
+	Level string `json:"level"`
+}
+
+// TracingConfig configures OpenTelemetry tracing.
+type TracingConfig struct {
+	// Exporter selects where spans are sent: "none" (tracing disabled), "stdout" (printed
+	// as JSON) or "otlp" (sent to an OTLP/HTTP collector).
+	Exporter string `json:"exporter"`
+	// Endpoint is the host:port of the OTLP collector.
+	Endpoint string `json:"endpoint"`
+	// Insecure sends spans to the collector over plain HTTP instead of HTTPS.
+	Insecure bool `json:"insecure"`
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string `json:"serviceName"`
+	// SampleRatio is the share of traces recorded, in [0, 1]. Requests carrying a
+	// sampled parent trace are always recorded.
+	SampleRatio float64 `json:"sampleRatio"`
+}
+
+// HealthConfig configures the readiness checks.
+type HealthConfig struct {
+	// RequireWarm keeps the server unready until the warm list has been fetched.
+	RequireWarm bool `json:"requireWarm"`
+	// MinBudgetShare is the share of an upstream's rate-limit budget that must be left.
+	MinBudgetShare float64 `json:"minBudgetShare"`
+	// MaxErrorRate is the highest acceptable share of failed upstream calls within
+	// ErrorWindow, once at least MinCalls calls were made.
+	MaxErrorRate float64  `json:"maxErrorRate"`
+	MinCalls     int      `json:"minCalls"`
+	ErrorWindow  Duration `json:"errorWindow"`
+	// UpstreamReadiness decides whether failing upstreams, i.e. with an open circuit
+	// breaker or an error rate over MaxErrorRate, make the server unready: "all", the
+	// default, fails readiness once every upstream is failing, "any" as soon as one is,
+	// and "none" only reports them in the status endpoint.
+	UpstreamReadiness string `json:"upstreamReadiness"`
+}
+
+// ClockConfig configures the measurement of upstream latency and clock offset.
+type ClockConfig struct {
+	// Interval is how often the server time of each upstream is measured.
+	Interval Duration `json:"interval"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	// Addr is the listen address, e.g. ":8080".
+	Addr string `json:"addr"`
+	// ShutdownTimeout is how long in-flight requests may take to finish on shutdown.
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+	// TrustedProxies lists the IPs and CIDR ranges of the reverse proxies whose
+	// X-Forwarded-For header is believed when identifying the client IP, e.g. for the
+	// per-IP limits of anonymous requests. When empty the header is ignored and the peer
+	// address is used.
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+// UpstreamConfig configures a Binance API service.
+type UpstreamConfig struct {
+	// BaseURL is the scheme and host of the upstream API, e.g. "https://api.binance.com".
+	BaseURL string `json:"baseUrl"`
+	// CacheTTL is how long a fetched response is kept in the cache.
+	CacheTTL Duration `json:"cacheTtl"`
+	// CacheDelay is the minimum time between two background refreshes of the same key.
+	CacheDelay Duration `json:"cacheDelay"`
+	// CachePolicies overrides CacheTTL and CacheDelay per cache name.
+	CachePolicies map[string]CachePolicy `json:"cachePolicies"`
+	// RequestTimeout is the deadline of a single upstream call.
+	RequestTimeout Duration `json:"requestTimeout"`
+	// EndpointTimeouts overrides RequestTimeout per cache name.
+	EndpointTimeouts map[string]Duration `json:"endpointTimeouts"`
+	// RateLimit configures the request-weight budget of the upstream.
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	// CircuitBreaker configures when calls to the upstream are suspended after failures.
+	CircuitBreaker CircuitBreakerConfig `json:"circuitBreaker"`
+	// Retry configures how transient upstream failures are retried.
+	Retry RetryConfig `json:"retry"`
+}
+
+// RateLimitConfig configures how much of the Binance request-weight limit may be used.
+type RateLimitConfig struct {
+	// WeightPerMinute is the Binance request-weight limit per IP and minute.
+	WeightPerMinute int `json:"weightPerMinute"`
+	// SafetyMargin is the fraction of WeightPerMinute kept in reserve, e.g. 0.1.
+	SafetyMargin float64 `json:"safetyMargin"`
+	// MaxWait is the longest a call is delayed waiting for the next window before it is rejected.
+	MaxWait Duration `json:"maxWait"`
+	// BanBackoff is how long calls are suspended after a 429 or 418 without a Retry-After header.
+	BanBackoff Duration `json:"banBackoff"`
+}
+
+// CircuitBreakerConfig configures the circuit breaker of an upstream host.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker.
+	FailureThreshold int `json:"failureThreshold"`
+	// OpenTimeout is how long the breaker stays open before letting probe calls through.
+	OpenTimeout Duration `json:"openTimeout"`
+	// HalfOpenMaxCalls is the number of probe calls allowed at once while half-open.
+	HalfOpenMaxCalls int `json:"halfOpenMaxCalls"`
+}
+
+// RetryConfig configures retries of transient upstream failures.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts per call, including the first. 1 disables retries.
+	MaxAttempts int `json:"maxAttempts"`
+	// BaseDelay is the backoff ceiling before the first retry; it doubles on every retry.
+	BaseDelay Duration `json:"baseDelay"`
+	// MaxDelay caps the backoff ceiling.
+	MaxDelay Duration `json:"maxDelay"`
+}
+
+// CacheConfig configures the local cache.
+type CacheConfig struct {
+	// CleanupInterval is how often expired entries are swept from the cache.
+	CleanupInterval Duration `json:"cleanupInterval"`
+	// RefreshConcurrency is the maximum number of background refreshes running at once.
+	RefreshConcurrency int `json:"refreshConcurrency"`
+	// StaleIfErrorGrace is how long an expired entry is kept to be served when the upstream
+	// fails. Zero disables stale serving.
+	StaleIfErrorGrace Duration `json:"staleIfErrorGrace"`
+	// MaxEntries is the maximum number of entries; the least recently used are evicted beyond it.
+	MaxEntries int `json:"maxEntries"`
+	// MaxBytes is the maximum approximate size of all cached values in bytes.
+	MaxBytes int64 `json:"maxBytes"`
+	// Backend selects where entries are stored: "memory" (per process), "redis" (shared)
+	// or "tiered" (an in-memory L1 over a shared Redis L2). MaxEntries and MaxBytes bound
+	// the in-memory tier.
+	Backend string `json:"backend"`
+	// Redis configures the Redis-protocol server used by the redis and tiered backends.
+	Redis RedisConfig `json:"redis"`
+	// Snapshot configures saving the in-memory cache to disk to warm it after a restart.
+	Snapshot SnapshotConfig `json:"snapshot"`
+	// Passthrough configures serving cached upstream bodies without re-encoding them.
+	Passthrough PassthroughConfig `json:"passthrough"`
+	// Containment configures answering depth and klines lookups from cached responses
+	// with a larger limit.
+	Containment ContainmentConfig `json:"containment"`
+}
+
+// ContainmentConfig configures serving a depth or klines lookup by slicing a fresh cached
+// response for the same symbol with a larger limit, and widening the fetches of hot
+// symbols so that one response answers all their commonly requested limits.
+type ContainmentConfig struct {
+	// Enabled turns serving from larger responses on.
+	Enabled bool `json:"enabled"`
+	// HotRequests is the number of requests for one symbol within Window from which its
+	// fetches are widened. Zero disables widening.
+	HotRequests int `json:"hotRequests"`
+	// MinShare is the share of the requests for a symbol that a limit needs to be
+	// considered commonly requested, in (0, 1].
+	MinShare float64 `json:"minShare"`
+	// Window is the period over which requests are counted.
+	Window Duration `json:"window"`
+}
+
+// PassthroughConfig configures pass-through responses. Cached upstream bodies are
+// always kept as received; pass-through writes them to clients as they are instead of
+// re-encoding the decoded models, so responses also carry fields the models drop.
+type PassthroughConfig struct {
+	// Enabled turns pass-through responses on.
+	Enabled bool `json:"enabled"`
+	// GzipMinBytes is the body size from which a gzip copy is prepared when the body is
+	// fetched, for clients that accept gzip. Zero disables compression.
+	GzipMinBytes int `json:"gzipMinBytes"`
+}
+
+// SnapshotConfig configures the on-disk snapshot of the in-memory cache.
+type SnapshotConfig struct {
+	// Path is the snapshot file. Snapshots are disabled while it is empty.
+	Path string `json:"path"`
+	// Interval is how often a snapshot is written while running, in addition to the one
+	// written on shutdown. Zero only writes on shutdown.
+	Interval Duration `json:"interval"`
+}
+
+// RedisConfig configures the connection to a Redis-protocol server.
+type RedisConfig struct {
+	// Addr is the host:port of the server.
+	Addr     string `json:"addr"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+	// KeyPrefix is prepended to every key so that several deployments can share a server.
+	KeyPrefix string `json:"keyPrefix"`
+	// Timeout bounds connecting and every single command.
+	Timeout Duration `json:"timeout"`
+}
+
+// HTTPConfig configures the HTTP client and transport shared by all upstream calls.
+type HTTPConfig struct {
+	// DialTimeout bounds establishing a TCP connection.
+	DialTimeout Duration `json:"dialTimeout"`
+	// TLSHandshakeTimeout bounds the TLS handshake.
+	TLSHandshakeTimeout Duration `json:"tlsHandshakeTimeout"`
+	// ResponseHeaderTimeout bounds the wait for response headers once the request is sent.
+	ResponseHeaderTimeout Duration `json:"responseHeaderTimeout"`
+	// IdleConnTimeout is how long an idle keep-alive connection is kept open.
+	IdleConnTimeout Duration `json:"idleConnTimeout"`
+	// MaxIdleConnsPerHost is the number of keep-alive connections kept per upstream host.
+	MaxIdleConnsPerHost int `json:"maxIdleConnsPerHost"`
+}
+
+// WarmConfig configures cache pre-warming and the scheduler that keeps hot keys fresh.
+type WarmConfig struct {
+	// Keys is the warm list, fetched at startup and then refreshed on schedule.
+	Keys []WarmKey `json:"keys"`
+	// Tick is how often the scheduler looks for keys due for a refresh.
+	Tick Duration `json:"tick"`
+	// IdleTimeout demotes a scheduled key that has not been read for this long.
+	IdleTimeout Duration `json:"idleTimeout"`
+	// PromoteReads schedules a key once it has been read this many times within
+	// IdleTimeout. Zero only schedules the warm list.
+	PromoteReads int `json:"promoteReads"`
+	// BudgetShare is the fraction of an upstream's rate-limit budget above which scheduled
+	// refreshes are skipped, leaving the rest to client requests.
+	BudgetShare float64 `json:"budgetShare"`
+}
+
+// WarmKey is one entry of the warm list.
+type WarmKey struct {
+	// Upstream is "spot" or "futures".
+	Upstream string `json:"upstream"`
+	// Endpoint is one of exchangeInfo, allPrices, tickerPrice, bookTicker, allBookTickers,
+	// ticker24hr, all24hrTickers, depth, klines, markPrice or fundingRate.
+	Endpoint string `json:"endpoint"`
+	Symbol   string `json:"symbol,omitempty"`
+	Interval string `json:"interval,omitempty"`
+	// Limit defaults to the limit the matching API route uses when none is given.
+	Limit int `json:"limit,omitempty"`
+}
+
+// AdminConfig configures access to the admin endpoints.
+type AdminConfig struct {
+	// Token is the credential expected in the Authorization header ("Bearer <token>").
+	// The admin endpoints are disabled while it is empty.
+	Token string `json:"token"`
+}
+
+// Default returns the configuration used when no file or environment overrides are given.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ShutdownTimeout: Duration(10 * time.Second),
+		},
+		Spot: UpstreamConfig{
+			BaseURL:          "https://api.binance.com",
+			CacheTTL:         Duration(1 * time.Minute),
+			CacheDelay:       Duration(500 * time.Millisecond),
+			CachePolicies:    defaultCachePolicies(),
+			RequestTimeout:   Duration(10 * time.Second),
+			EndpointTimeouts: defaultEndpointTimeouts(),
+			RateLimit: RateLimitConfig{
+				WeightPerMinute: 6000,
+				SafetyMargin:    0.1,
+				MaxWait:         Duration(2 * time.Second),
+				BanBackoff:      Duration(1 * time.Minute),
+			},
+			CircuitBreaker: CircuitBreakerConfig{
+				FailureThreshold: 5,
+				OpenTimeout:      Duration(30 * time.Second),
+				HalfOpenMaxCalls: 1,
+			},
+			Retry: RetryConfig{
+				MaxAttempts: 3,
+				BaseDelay:   Duration(100 * time.Millisecond),
+				MaxDelay:    Duration(2 * time.Second),
+			},
+		},
+		Futures: UpstreamConfig{
+			BaseURL:          "https://fapi.binance.com",
+			CacheTTL:         Duration(1 * time.Minute),
+			CacheDelay:       Duration(500 * time.Millisecond),
+			CachePolicies:    defaultCachePolicies(),
+			RequestTimeout:   Duration(10 * time.Second),
+			EndpointTimeouts: defaultEndpointTimeouts(),
+			RateLimit: RateLimitConfig{
+				WeightPerMinute: 2400,
+				SafetyMargin:    0.1,
+				MaxWait:         Duration(2 * time.Second),
+				BanBackoff:      Duration(1 * time.Minute),
+			},
+			CircuitBreaker: CircuitBreakerConfig{
+				FailureThreshold: 5,
+				OpenTimeout:      Duration(30 * time.Second),
+				HalfOpenMaxCalls: 1,
+			},
+			Retry: RetryConfig{
+				MaxAttempts: 3,
+				BaseDelay:   Duration(100 * time.Millisecond),
+				MaxDelay:    Duration(2 * time.Second),
+			},
+		},
+		Cache: CacheConfig{
+			CleanupInterval:    Duration(10 * time.Minute),
+			RefreshConcurrency: 8,
+			StaleIfErrorGrace:  Duration(5 * time.Minute),
+			MaxEntries:         50000,
+			MaxBytes:           256 << 20,
+			Backend:            "memory",
+			Redis: RedisConfig{
+				Addr:      "localhost:6379",
+				KeyPrefix: "go-crypto:",
+				Timeout:   Duration(200 * time.Millisecond),
+			},
+			Snapshot: SnapshotConfig{
+				Interval: Duration(5 * time.Minute),
+			},
+			Passthrough: PassthroughConfig{
+				GzipMinBytes: 4096,
+			},
+			Containment: ContainmentConfig{
+				Enabled:     true,
+				HotRequests: 20,
+				MinShare:    0.1,
+				Window:      Duration(time.Minute),
+			},
+		},
+		HTTP: HTTPConfig{
+			DialTimeout:           Duration(5 * time.Second),
+			TLSHandshakeTimeout:   Duration(5 * time.Second),
+			ResponseHeaderTimeout: Duration(10 * time.Second),
+			IdleConnTimeout:       Duration(90 * time.Second),
+			MaxIdleConnsPerHost:   32,
+		},
+		Clock: ClockConfig{
+			Interval: Duration(30 * time.Second),
+		},
+		Health: HealthConfig{
+			RequireWarm:       true,
+			MinBudgetShare:    0.05,
+			MaxErrorRate:      0.5,
+			MinCalls:          10,
+			ErrorWindow:       Duration(time.Minute),
+			UpstreamReadiness: "all",
+		},
+		Auth: AuthConfig{
+			Header: "X-API-Key",
+			Tiers: map[string]RateTier{
+				"anonymous": {RatePerSecond: 1, Burst: 10, DailyQuota: 5000},
+				"standard":  {RatePerSecond: 10, Burst: 50, DailyQuota: 200000},
+			},
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+			AllowedHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "If-None-Match", "X-API-Key", "X-Request-ID"},
+			ExposedHeaders: []string{
+				"Content-Length", "X-Cache", "Age", "ETag", "X-Request-ID", "Retry-After",
+				"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset",
+				"X-RateLimit-Quota-Limit", "X-RateLimit-Quota-Remaining", "X-RateLimit-Quota-Reset",
+			},
+			MaxAge: Duration(10 * time.Minute),
+		},
+		Log: LogConfig{
+			Format: "text",
+			Level:  "info",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "go-crypto",
+			SampleRatio: 1,
+		},
+		Warm: WarmConfig{
+			Keys: []WarmKey{
+				{Upstream: "spot", Endpoint: "exchangeInfo"},
+				{Upstream: "spot", Endpoint: "allPrices"},
+				{Upstream: "spot", Endpoint: "klines", Symbol: "BTCUSDT", Interval: "1m"},
+				{Upstream: "spot", Endpoint: "klines", Symbol: "BTCUSDT", Interval: "1h"},
+				{Upstream: "spot", Endpoint: "klines", Symbol: "ETHUSDT", Interval: "1m"},
+				{Upstream: "spot", Endpoint: "klines", Symbol: "ETHUSDT", Interval: "1h"},
+			},
+			Tick:         Duration(1 * time.Second),
+			IdleTimeout:  Duration(10 * time.Minute),
+			PromoteReads: 20,
+			BudgetShare:  0.5,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the optional JSON file at path and
+// the environment, in that order, and validates the result.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+		}
+	}
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Auth.loadKeysFile(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that the configuration is usable.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout must be positive"))
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("server.trustedProxies: %q is not an IP or CIDR range", proxy))
+			}
+		}
+	}
+	errs = append(errs, c.Spot.validate("spot")...)
+	errs = append(errs, c.Futures.validate("futures")...)
+	if c.Cache.CleanupInterval <= 0 {
+		errs = append(errs, errors.New("cache.cleanupInterval must be positive"))
+	}
+	if c.Cache.RefreshConcurrency <= 0 {
+		errs = append(errs, errors.New("cache.refreshConcurrency must be positive"))
+	}
+	if c.Cache.StaleIfErrorGrace < 0 {
+		errs = append(errs, errors.New("cache.staleIfErrorGrace must not be negative"))
+	}
+	if c.Cache.MaxEntries <= 0 || c.Cache.MaxBytes <= 0 {
+		errs = append(errs, errors.New("cache.maxEntries and cache.maxBytes must be positive"))
+	}
+	if c.Cache.Snapshot.Interval < 0 {
+		errs = append(errs, errors.New("cache.snapshot.interval must not be negative"))
+	}
+	if c.Cache.Passthrough.GzipMinBytes < 0 {
+		errs = append(errs, errors.New("cache.passthrough.gzipMinBytes must not be negative"))
+	}
+	if c.Cache.Containment.HotRequests < 0 {
+		errs = append(errs, errors.New("cache.containment.hotRequests must not be negative"))
+	}
+	if c.Cache.Containment.MinShare <= 0 || c.Cache.Containment.MinShare > 1 {
+		errs = append(errs, errors.New("cache.containment.minShare must be in (0, 1]"))
+	}
+	if c.Cache.Containment.Window <= 0 {
+		errs = append(errs, errors.New("cache.containment.window must be positive"))
+	}
+	errs = append(errs, c.Warm.validate()...)
+	errs = append(errs, c.Auth.validate()...)
+	errs = append(errs, c.CORS.validate()...)
+	if c.Clock.Interval <= 0 {
+		errs = append(errs, errors.New("clock.interval must be positive"))
+	}
+	if c.Health.MinBudgetShare < 0 || c.Health.MinBudgetShare > 1 {
+		errs = append(errs, errors.New("health.minBudgetShare must be in [0, 1]"))
+	}
+	if c.Health.MaxErrorRate < 0 || c.Health.MaxErrorRate > 1 {
+		errs = append(errs, errors.New("health.maxErrorRate must be in [0, 1]"))
+	}
+	if c.Health.MinCalls < 0 {
+		errs = append(errs, errors.New("health.minCalls must not be negative"))
+	}
+	if c.Health.ErrorWindow <= 0 {
+		errs = append(errs, errors.New("health.errorWindow must be positive"))
+	}
+	switch c.Health.UpstreamReadiness {
+	case "none", "all", "any":
+	default:
+		errs = append(errs, fmt.Errorf("health.upstreamReadiness must be none, all or any, got %q", c.Health.UpstreamReadiness))
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("log.format must be text or json, got %q", c.Log.Format))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint == "" {
+			errs = append(errs, errors.New("tracing.endpoint must not be empty"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sampleRatio must be in [0, 1]"))
+	}
+	switch c.Cache.Backend {
+	case "memory":
+	case "redis", "tiered":
+		if c.Cache.Redis.Addr == "" {
+			errs = append(errs, errors.New("cache.redis.addr must not be empty"))
+		}
+		if c.Cache.Redis.Timeout <= 0 {
+			errs = append(errs, errors.New("cache.redis.timeout must be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cache.backend must be memory, redis or tiered, got %q", c.Cache.Backend))
+	}
+	if c.HTTP.DialTimeout <= 0 || c.HTTP.TLSHandshakeTimeout <= 0 || c.HTTP.ResponseHeaderTimeout <= 0 || c.HTTP.IdleConnTimeout <= 0 {
+		errs = append(errs, errors.New("http timeouts must be positive"))
+	}
+	if c.HTTP.MaxIdleConnsPerHost <= 0 {
+		errs = append(errs, errors.New("http.maxIdleConnsPerHost must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func (u UpstreamConfig) validate(name string) []error {
+	var errs []error
+	parsed, err := url.Parse(u.BaseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, fmt.Errorf("%s.baseUrl must be an absolute http(s) URL, got %q", name, u.BaseURL))
+	}
+	if u.CacheTTL <= 0 {
+		errs = append(errs, fmt.Errorf("%s.cacheTtl must be positive", name))
+	}
+	if u.CacheDelay < 0 {
+		errs = append(errs, fmt.Errorf("%s.cacheDelay must not be negative", name))
+	}
+	if u.CacheDelay > u.CacheTTL {
+		errs = append(errs, fmt.Errorf("%s.cacheDelay must not exceed %s.cacheTtl", name, name))
+	}
+	if u.RequestTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("%s.requestTimeout must be positive", name))
+	}
+	if u.RateLimit.WeightPerMinute <= 0 {
+		errs = append(errs, fmt.Errorf("%s.rateLimit.weightPerMinute must be positive", name))
+	}
+	if u.RateLimit.SafetyMargin < 0 || u.RateLimit.SafetyMargin >= 1 {
+		errs = append(errs, fmt.Errorf("%s.rateLimit.safetyMargin must be in [0, 1)", name))
+	}
+	if u.RateLimit.MaxWait < 0 || u.RateLimit.BanBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("%s.rateLimit.maxWait must not be negative and banBackoff must be positive", name))
+	}
+	if u.CircuitBreaker.FailureThreshold <= 0 || u.CircuitBreaker.HalfOpenMaxCalls <= 0 {
+		errs = append(errs, fmt.Errorf("%s.circuitBreaker.failureThreshold and halfOpenMaxCalls must be positive", name))
+	}
+	if u.CircuitBreaker.OpenTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("%s.circuitBreaker.openTimeout must be positive", name))
+	}
+	if u.Retry.MaxAttempts <= 0 {
+		errs = append(errs, fmt.Errorf("%s.retry.maxAttempts must be positive", name))
+	}
+	if u.Retry.BaseDelay < 0 || u.Retry.MaxDelay < u.Retry.BaseDelay {
+		errs = append(errs, fmt.Errorf("%s.retry.baseDelay must not be negative or exceed maxDelay", name))
+	}
+	for cacheName, timeout := range u.EndpointTimeouts {
+		if timeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s.endpointTimeouts.%s must be positive", name, cacheName))
+		}
+	}
+	for cacheName, policy := range u.CachePolicies {
+		errs = append(errs, policy.validate(fmt.Sprintf("%s.cachePolicies.%s", name, cacheName), u.CacheTTL, u.CacheDelay)...)
+	}
+	return errs
+}
+
+// loadKeysFile adds the keys of KeysFile, if set, to Keys.
+func (a *AuthConfig) loadKeysFile() error {
+	if a.KeysFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(a.KeysFile)
+	if err != nil {
+		return fmt.Errorf("error reading API keys file %s: %w", a.KeysFile, err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("error parsing API keys file %s: %w", a.KeysFile, err)
+	}
+	a.Keys = append(a.Keys, keys...)
+	return nil
+}
+
+func (a AuthConfig) validate() []error {
+	if !a.Enabled {
+		return nil
+	}
+	var errs []error
+	if a.Header == "" {
+		errs = append(errs, errors.New("auth.header must not be empty"))
+	}
+	for name, tier := range a.Tiers {
+		if tier.RatePerSecond <= 0 || tier.Burst <= 0 {
+			errs = append(errs, fmt.Errorf("auth.tiers.%s.ratePerSecond and burst must be positive", name))
+		}
+		if tier.DailyQuota < 0 {
+			errs = append(errs, fmt.Errorf("auth.tiers.%s.dailyQuota must not be negative", name))
+		}
+	}
+	if _, ok := a.Tiers[a.AnonymousTier]; a.AnonymousTier != "" && !ok {
+		errs = append(errs, fmt.Errorf("auth.anonymousTier %q is not a configured tier", a.AnonymousTier))
+	}
+	seen := make(map[string]bool, len(a.Keys))
+	names := make(map[string]bool, len(a.Keys))
+	for i, key := range a.Keys {
+		if key.Key == "" {
+			errs = append(errs, fmt.Errorf("auth.keys[%d].key must not be empty", i))
+		} else if seen[key.Key] {
+			errs = append(errs, fmt.Errorf("auth.keys[%d] repeats a key", i))
+		}
+		seen[key.Key] = true
+		// Usage is reported by name, so unnamed keys starting alike need names.
+		if name := key.ClientName(); names[name] {
+			errs = append(errs, fmt.Errorf("auth.keys[%d].name %q is already used; give the key a distinct name", i, name))
+		} else {
+			names[name] = true
+		}
+		if _, ok := a.Tiers[key.Tier]; !ok {
+			errs = append(errs, fmt.Errorf("auth.keys[%d].tier %q is not a configured tier", i, key.Tier))
+		}
+	}
+	return errs
+}
+
+func (c CORSConfig) validate() []error {
+	var errs []error
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				errs = append(errs, errors.New("cors.allowedOrigins must list the origins, not \"*\", when cors.allowCredentials is set"))
+			}
+			continue
+		}
+		parsed, err := url.Parse(strings.Replace(origin, "*.", "wildcard.", 1))
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+			parsed.Path != "" || parsed.RawQuery != "" || parsed.User != nil ||
+			strings.Contains(strings.TrimPrefix(origin, parsed.Scheme+"://*."), "*") {
+			errs = append(errs, fmt.Errorf("cors.allowedOrigins entry %q must be \"*\", scheme://host[:port] or scheme://*.host[:port]", origin))
+		}
+	}
+	if len(c.AllowedMethods) == 0 {
+		errs = append(errs, errors.New("cors.allowedMethods must not be empty"))
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.maxAge must not be negative"))
+	}
+	return errs
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthValidateRejectsDuplicateClientNames(t *testing.T) {
+	tests := []struct {
+		keys []APIKey
+		ok   bool
+	}{
+		{[]APIKey{{Key: "abcd1111"}, {Key: "abcd2222"}}, false},
+		{[]APIKey{{Key: "abcd1111", Name: "alice"}, {Key: "abcd2222", Name: "alice"}}, false},
+		{[]APIKey{{Key: "abcd1111", Name: "abcd…"}, {Key: "abcd2222", Name: "bob"}}, true},
+		{[]APIKey{{Key: "abcd1111"}, {Key: "wxyz2222", Name: "abcd…"}}, false},
+		{[]APIKey{{Key: "abcd1111"}, {Key: "abcd2222", Name: "bob"}}, true},
+	}
+	for _, test := range tests {
+		cfg := Default().Auth
+		cfg.Enabled = true
+		for i := range test.keys {
+			test.keys[i].Tier = "standard"
+		}
+		cfg.Keys = test.keys
+		errs := cfg.validate()
+		if ok := len(errs) == 0; ok != test.ok {
+			t.Errorf("keys %+v: errors %v, want ok = %v", test.keys, errs, test.ok)
+		}
+		for _, err := range errs {
+			if !strings.Contains(err.Error(), "already used") {
+				t.Errorf("keys %+v: unexpected error %v", test.keys, err)
+			}
+		}
+	}
+}
+
+func TestCachePolicyValidateUsesEffectiveTTL(t *testing.T) {
+	second := Duration(time.Second)
+	tests := []struct {
+		policy CachePolicy
+		ok     bool
+	}{
+		{CachePolicy{TTL: 10 * second, RefreshInterval: 5 * second}, true},
+		{CachePolicy{TTL: 10 * second, RefreshInterval: 20 * second}, false},
+		// Without a TTL of its own, the policy is checked against the upstream's.
+		{CachePolicy{RefreshInterval: 3 * second}, true},
+		{CachePolicy{RefreshInterval: 20 * second}, false},
+		// Without a refresh interval of its own, the upstream's delay applies.
+		{CachePolicy{TTL: 2 * second}, true},
+		{CachePolicy{TTL: second}, false},
+	}
+	for _, test := range tests {
+		upstream := Default().Spot
+		upstream.CacheTTL = 5 * second
+		upstream.CacheDelay = 2 * second
+		upstream.CachePolicies = map[string]CachePolicy{"depth": test.policy}
+		errs := upstream.validate("spot")
+		if ok := len(errs) == 0; ok != test.ok {
+			t.Errorf("policy %+v: errors %v, want ok = %v", test.policy, errs, test.ok)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envVar maps an environment variable onto a configuration field.
+type envVar struct {
+	name  string
+	apply func(cfg *Config, value string) error
+}
+
+// envVars lists every supported environment override. PORT is kept for
+// compatibility with hosting platforms that only set a port number.
+var envVars = []envVar{
+	{"PORT", func(cfg *Config, v string) error { cfg.Server.Addr = ":" + v; return nil }},
+	{"SERVER_ADDR", func(cfg *Config, v string) error { cfg.Server.Addr = v; return nil }},
+	{"SERVER_TRUSTED_PROXIES", listVar(func(cfg *Config) *[]string { return &cfg.Server.TrustedProxies })},
+	{"SPOT_BASE_URL", func(cfg *Config, v string) error { cfg.Spot.BaseURL = v; return nil }},
+	{"SPOT_CACHE_TTL", durationVar(func(cfg *Config) *Duration { return &cfg.Spot.CacheTTL })},
+	{"SPOT_CACHE_DELAY", durationVar(func(cfg *Config) *Duration { return &cfg.Spot.CacheDelay })},
+	{"SPOT_REQUEST_TIMEOUT", durationVar(func(cfg *Config) *Duration { return &cfg.Spot.RequestTimeout })},
+	{"SPOT_WEIGHT_PER_MINUTE", intVar(func(cfg *Config) *int { return &cfg.Spot.RateLimit.WeightPerMinute })},
+	{"FUTURES_BASE_URL", func(cfg *Config, v string) error { cfg.Futures.BaseURL = v; return nil }},
+	{"FUTURES_CACHE_TTL", durationVar(func(cfg *Config) *Duration { return &cfg.Futures.CacheTTL })},
+	{"FUTURES_CACHE_DELAY", durationVar(func(cfg *Config) *Duration { return &cfg.Futures.CacheDelay })},
+	{"FUTURES_REQUEST_TIMEOUT", durationVar(func(cfg *Config) *Duration { return &cfg.Futures.RequestTimeout })},
+	{"FUTURES_WEIGHT_PER_MINUTE", intVar(func(cfg *Config) *int { return &cfg.Futures.RateLimit.WeightPerMinute })},
+	{"CACHE_CLEANUP_INTERVAL", durationVar(func(cfg *Config) *Duration { return &cfg.Cache.CleanupInterval })},
+	{"CACHE_STALE_IF_ERROR_GRACE", durationVar(func(cfg *Config) *Duration { return &cfg.Cache.StaleIfErrorGrace })},
+	{"CACHE_REFRESH_CONCURRENCY", intVar(func(cfg *Config) *int { return &cfg.Cache.RefreshConcurrency })},
+	{"CACHE_MAX_ENTRIES", intVar(func(cfg *Config) *int { return &cfg.Cache.MaxEntries })},
+	{"CACHE_MAX_BYTES", int64Var(func(cfg *Config) *int64 { return &cfg.Cache.MaxBytes })},
+	{"CACHE_SNAPSHOT_PATH", func(cfg *Config, v string) error { cfg.Cache.Snapshot.Path = v; return nil }},
+	{"CACHE_SNAPSHOT_INTERVAL", durationVar(func(cfg *Config) *Duration { return &cfg.Cache.Snapshot.Interval })},
+	{"CACHE_PASSTHROUGH", boolVar(func(cfg *Config) *bool { return &cfg.Cache.Passthrough.Enabled })},
+	{"CACHE_CONTAINMENT", boolVar(func(cfg *Config) *bool { return &cfg.Cache.Containment.Enabled })},
+	{"CACHE_BACKEND", func(cfg *Config, v string) error { cfg.Cache.Backend = v; return nil }},
+	{"REDIS_ADDR", func(cfg *Config, v string) error { cfg.Cache.Redis.Addr = v; return nil }},
+	{"REDIS_USERNAME", func(cfg *Config, v string) error { cfg.Cache.Redis.Username = v; return nil }},
+	{"REDIS_PASSWORD", func(cfg *Config, v string) error { cfg.Cache.Redis.Password = v; return nil }},
+	{"REDIS_DB", intVar(func(cfg *Config) *int { return &cfg.Cache.Redis.DB })},
+	{"HEALTH_UPSTREAM_READINESS", func(cfg *Config, v string) error { cfg.Health.UpstreamReadiness = v; return nil }},
+	{"TRACING_EXPORTER", func(cfg *Config, v string) error { cfg.Tracing.Exporter = v; return nil }},
+	{"TRACING_ENDPOINT", func(cfg *Config, v string) error { cfg.Tracing.Endpoint = v; return nil }},
+	{"TRACING_SAMPLE_RATIO", float64Var(func(cfg *Config) *float64 { return &cfg.Tracing.SampleRatio })},
+	{"LOG_FORMAT", func(cfg *Config, v string) error { cfg.Log.Format = v; return nil }},
+	{"LOG_LEVEL", func(cfg *Config, v string) error { cfg.Log.Level = v; return nil }},
+	{"AUTH_ENABLED", boolVar(func(cfg *Config) *bool { return &cfg.Auth.Enabled })},
+	{"AUTH_KEYS_FILE", func(cfg *Config, v string) error { cfg.Auth.KeysFile = v; return nil }},
+	{"AUTH_ANONYMOUS_TIER", func(cfg *Config, v string) error { cfg.Auth.AnonymousTier = v; return nil }},
+	{"CORS_ALLOWED_ORIGINS", listVar(func(cfg *Config) *[]string { return &cfg.CORS.AllowedOrigins })},
+	{"CORS_ALLOW_CREDENTIALS", boolVar(func(cfg *Config) *bool { return &cfg.CORS.AllowCredentials })},
+	{"ADMIN_TOKEN", func(cfg *Config, v string) error { cfg.Admin.Token = v; return nil }},
+}
+
+// applyEnv overrides cfg with every environment variable that is set.
+func applyEnv(cfg *Config) error {
+	for _, ev := range envVars {
+		value, ok := os.LookupEnv(ev.name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := ev.apply(cfg, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", ev.name, err)
+		}
+	}
+	return nil
+}
+
+func durationVar(field func(cfg *Config) *Duration) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = Duration(d)
+		return nil
+	}
+}
+
+func intVar(field func(cfg *Config) *int) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func int64Var(field func(cfg *Config) *int64) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func boolVar(field func(cfg *Config) *bool) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = b
+		return nil
+	}
+}
+
+func float64Var(field func(cfg *Config) *float64) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = f
+		return nil
+	}
+}
+
+// listVar parses a comma-separated list.
+func listVar(field func(cfg *Config) *[]string) func(cfg *Config, value string) error {
+	return func(cfg *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(cfg) = list
+		return nil
+	}
+}
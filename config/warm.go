@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// warmEndpoints lists the endpoints a WarmKey may use by upstream, and whether they
+// need a symbol and an interval.
+var warmEndpoints = map[string]map[string]struct{ symbol, interval bool }{
+	"spot": {
+		"exchangeInfo":   {},
+		"allPrices":      {},
+		"allBookTickers": {},
+		"tickerPrice":    {symbol: true},
+		"bookTicker":     {symbol: true},
+		"ticker24hr":     {symbol: true},
+		"depth":          {symbol: true},
+		"klines":         {symbol: true, interval: true},
+	},
+	"futures": {
+		"exchangeInfo":   {},
+		"allPrices":      {},
+		"all24hrTickers": {},
+		"tickerPrice":    {symbol: true},
+		"bookTicker":     {symbol: true},
+		"ticker24hr":     {symbol: true},
+		"depth":          {symbol: true},
+		"klines":         {symbol: true, interval: true},
+		"markPrice":      {symbol: true},
+		"fundingRate":    {symbol: true},
+	},
+}
+
+// UnmarshalJSON decodes into a zero WarmKey. encoding/json reuses the elements of the
+// default warm list when a config file replaces it, which would otherwise leave fields
+// of the defaults behind.
+func (k *WarmKey) UnmarshalJSON(data []byte) error {
+	type plain WarmKey
+	var v plain
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*k = WarmKey(v)
+	return nil
+}
+
+func (w WarmConfig) validate() []error {
+	var errs []error
+	if w.Tick <= 0 || w.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("warm.tick and warm.idleTimeout must be positive"))
+	}
+	if w.PromoteReads < 0 {
+		errs = append(errs, errors.New("warm.promoteReads must not be negative"))
+	}
+	if w.BudgetShare <= 0 || w.BudgetShare > 1 {
+		errs = append(errs, errors.New("warm.budgetShare must be in (0, 1]"))
+	}
+	for i, key := range w.Keys {
+		name := fmt.Sprintf("warm.keys[%d]", i)
+		endpoints, ok := warmEndpoints[key.Upstream]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s.upstream must be spot or futures, got %q", name, key.Upstream))
+			continue
+		}
+		needs, ok := endpoints[key.Endpoint]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s.endpoint %q is not supported for %s", name, key.Endpoint, key.Upstream))
+			continue
+		}
+		if needs.symbol != (key.Symbol != "") {
+			errs = append(errs, fmt.Errorf("%s.symbol is required by %s and not allowed otherwise", name, key.Endpoint))
+		}
+		if needs.interval != (key.Interval != "") {
+			errs = append(errs, fmt.Errorf("%s.interval is required by %s and not allowed otherwise", name, key.Endpoint))
+		}
+		if key.Limit < 0 {
+			errs = append(errs, fmt.Errorf("%s.limit must not be negative", name))
+		}
+	}
+	return errs
+}
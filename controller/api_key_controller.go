@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+type APIKeyController interface {
+	Usage(ctx *gin.Context)
+}
+
+type apiKeyController struct {
+	auth service.APIKeyAuth
+}
+
+// NewAPIKeyController creates and returns a new APIKeyController instance.
+func NewAPIKeyController(auth service.APIKeyAuth) APIKeyController {
+	return &apiKeyController{
+		auth: auth,
+	}
+}
+
+// Usage handles the /admin/auth/usage endpoint: the requests made with every API key
+// and anonymously.
+func (c *apiKeyController) Usage(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.auth.Usage())
+}
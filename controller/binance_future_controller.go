@@ -1,8 +1,7 @@
 package controller
 
 import (
-	"log"
-	"net/http"
+	"log/slog"
 	"strconv"
 
 	// Import time package for parsing timestamps
@@ -43,124 +42,124 @@ func NewBinanceFutureController(binanceService service.BinanceFuturesService) Bi
 
 // FuturesPing handles the /fapi/v1/ping endpoint.
 func (c *binanceFutureController) FuturesPing(ctx *gin.Context) {
-	resp, err := c.binanceService.GetPing()
+	resp, err := c.binanceService.GetPing(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in FuturesPing: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesPing failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesTime handles the /fapi/v1/time endpoint.
 func (c *binanceFutureController) FuturesTime(ctx *gin.Context) {
-	resp, err := c.binanceService.GetTime()
+	resp, err := c.binanceService.GetTime(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in FuturesTime: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesTime failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesExchangeInfo handles the /fapi/v1/exchangeInfo endpoint.
 func (c *binanceFutureController) FuturesExchangeInfo(ctx *gin.Context) {
-	resp, err := c.binanceService.GetExchangeInfo()
+	resp, err := c.binanceService.GetExchangeInfo(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in FuturesExchangeInfo: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesExchangeInfo failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesDepth handles the /fapi/v1/depth endpoint.
 func (c *binanceFutureController) FuturesDepth(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
 	limitStr := ctx.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
-	resp, err := c.binanceService.GetDepth(symbol, limit)
+	resp, err := c.binanceService.GetDepth(ctx.Request.Context(), symbol, limit)
 	if err != nil {
-		log.Printf("Error in FuturesDepth for symbol %s, limit %d: %v", symbol, limit, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesDepth failed", "symbol", symbol, "limit", limit, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesAggTrades handles the /fapi/v1/aggTrades endpoint.
 func (c *binanceFutureController) FuturesAggTrades(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
 	limitStr := ctx.DefaultQuery("limit", "500") // Default for aggTrades might be higher
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
-	resp, err := c.binanceService.GetAggTrades(symbol, limit)
+	resp, err := c.binanceService.GetAggTrades(ctx.Request.Context(), symbol, limit)
 	if err != nil {
-		log.Printf("Error in FuturesAggTrades for symbol %s, limit %d: %v", symbol, limit, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesAggTrades failed", "symbol", symbol, "limit", limit, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesTickerPrice handles the /fapi/v1/ticker/price endpoint for a single symbol.
 func (c *binanceFutureController) FuturesTickerPrice(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
-	resp, err := c.binanceService.GetTickerPrice(symbol)
+	resp, err := c.binanceService.GetTickerPrice(ctx.Request.Context(), symbol)
 	if err != nil {
-		log.Printf("Error in FuturesTickerPrice for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesTickerPrice failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesAllTickerPrices handles the /fapi/v1/ticker/price endpoint for all symbols.
 func (c *binanceFutureController) FuturesAllTickerPrices(ctx *gin.Context) {
-	resp, err := c.binanceService.GetAllTickerPrices()
+	resp, err := c.binanceService.GetAllTickerPrices(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in FuturesAllTickerPrices: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesAllTickerPrices failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesBookTicker returns the best price/qty on the order book for a symbol.
 func (c *binanceFutureController) FuturesBookTicker(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
-	resp, err := c.binanceService.GetBookTicker(symbol)
+	resp, err := c.binanceService.GetBookTicker(ctx.Request.Context(), symbol)
 	if err != nil {
-		log.Printf("Error in FuturesBookTicker for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesBookTicker failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesKlines handles the /fapi/v1/klines endpoint.
@@ -168,46 +167,46 @@ func (c *binanceFutureController) FuturesKlines(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	interval := ctx.Query("interval")
 	if symbol == "" || interval == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol and interval query parameters are required"})
+		respondBadRequest(ctx, "symbol and interval query parameters are required")
 		return
 	}
 	limitStr := ctx.DefaultQuery("limit", "500")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
-	resp, err := c.binanceService.GetKlines(symbol, interval, limit)
+	resp, err := c.binanceService.GetKlines(ctx.Request.Context(), symbol, interval, limit)
 	if err != nil {
-		log.Printf("Error in FuturesKlines for symbol %s, interval %s, limit %d: %v", symbol, interval, limit, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesKlines failed", "symbol", symbol, "interval", interval, "limit", limit, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesMarkPrice handles the /fapi/v1/premiumIndex endpoint.
 func (c *binanceFutureController) FuturesMarkPrice(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
-	resp, err := c.binanceService.GetMarkPrice(symbol)
+	resp, err := c.binanceService.GetMarkPrice(ctx.Request.Context(), symbol)
 	if err != nil {
-		log.Printf("Error in FuturesMarkPrice for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesMarkPrice failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesAllForceOrders handles the /fapi/v1/allForceOrders endpoint.
 func (c *binanceFutureController) FuturesAllForceOrders(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
 
@@ -217,7 +216,7 @@ func (c *binanceFutureController) FuturesAllForceOrders(ctx *gin.Context) {
 	if s := ctx.Query("startTime"); s != "" {
 		t, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid startTime parameter"})
+			respondBadRequest(ctx, "invalid startTime parameter")
 			return
 		}
 		startTime = &t
@@ -225,7 +224,7 @@ func (c *binanceFutureController) FuturesAllForceOrders(ctx *gin.Context) {
 	if s := ctx.Query("endTime"); s != "" {
 		t, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid endTime parameter"})
+			respondBadRequest(ctx, "invalid endTime parameter")
 			return
 		}
 		endTime = &t
@@ -234,51 +233,51 @@ func (c *binanceFutureController) FuturesAllForceOrders(ctx *gin.Context) {
 	limitStr := ctx.DefaultQuery("limit", "500")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
-	resp, err := c.binanceService.GetAllForceOrders(symbol, autoCloseType, startTime, endTime, limit)
+	resp, err := c.binanceService.GetAllForceOrders(ctx.Request.Context(), symbol, autoCloseType, startTime, endTime, limit)
 	if err != nil {
-		log.Printf("Error in FuturesAllForceOrders for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesAllForceOrders failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // Futures24HrTicker handles the /fapi/v1/ticker/24hr endpoint for a single symbol.
 func (c *binanceFutureController) Futures24HrTicker(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
-	resp, err := c.binanceService.Get24HrTicker(symbol)
+	resp, err := c.binanceService.Get24HrTicker(ctx.Request.Context(), symbol)
 	if err != nil {
-		log.Printf("Error in Futures24HrTicker for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "Futures24HrTicker failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesAll24HrTickers handles the /fapi/v1/ticker/24hr endpoint for all symbols.
 func (c *binanceFutureController) FuturesAll24HrTickers(ctx *gin.Context) {
-	resp, err := c.binanceService.GetAll24HrTickers()
+	resp, err := c.binanceService.GetAll24HrTickers(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in FuturesAll24HrTickers: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesAll24HrTickers failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesFundingRate handles the /fapi/v1/fundingRate endpoint.
 func (c *binanceFutureController) FuturesFundingRate(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
 
@@ -286,7 +285,7 @@ func (c *binanceFutureController) FuturesFundingRate(ctx *gin.Context) {
 	if s := ctx.Query("startTime"); s != "" {
 		t, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid startTime parameter"})
+			respondBadRequest(ctx, "invalid startTime parameter")
 			return
 		}
 		startTime = &t
@@ -294,7 +293,7 @@ func (c *binanceFutureController) FuturesFundingRate(ctx *gin.Context) {
 	if s := ctx.Query("endTime"); s != "" {
 		t, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid endTime parameter"})
+			respondBadRequest(ctx, "invalid endTime parameter")
 			return
 		}
 		endTime = &t
@@ -303,30 +302,30 @@ func (c *binanceFutureController) FuturesFundingRate(ctx *gin.Context) {
 	limitStr := ctx.DefaultQuery("limit", "100") // Default limit for funding rate
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
-	resp, err := c.binanceService.GetFundingRate(symbol, startTime, endTime, limit)
+	resp, err := c.binanceService.GetFundingRate(ctx.Request.Context(), symbol, startTime, endTime, limit)
 	if err != nil {
-		log.Printf("Error in FuturesFundingRate for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesFundingRate failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // FuturesRecentTrades handles the /fapi/v1/trades endpoint.
 func (c *binanceFutureController) FuturesRecentTrades(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
 	limitStr := ctx.DefaultQuery("limit", "500")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
@@ -334,17 +333,17 @@ func (c *binanceFutureController) FuturesRecentTrades(ctx *gin.Context) {
 	if s := ctx.Query("fromId"); s != "" {
 		id, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid fromId parameter"})
+			respondBadRequest(ctx, "invalid fromId parameter")
 			return
 		}
 		fromId = &id
 	}
 
-	resp, err := c.binanceService.GetRecentTrades(symbol, limit, fromId)
+	resp, err := c.binanceService.GetRecentTrades(ctx.Request.Context(), symbol, limit, fromId)
 	if err != nil {
-		log.Printf("Error in FuturesRecentTrades for symbol %s, limit %d, fromId %v: %v", symbol, limit, fromId, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "FuturesRecentTrades failed", "symbol", symbol, "limit", limit, "fromId", ctx.Query("fromId"), "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
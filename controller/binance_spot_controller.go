@@ -1,8 +1,7 @@
 package controller
 
 import (
-	"log"
-	"net/http"
+	"log/slog"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -39,124 +38,124 @@ func NewBinanceSpotController(binanceService service.BinanceSpotService) Binance
 
 // Ping handles the /api/v3/ping endpoint.
 func (c *binanceSpotController) Ping(ctx *gin.Context) {
-	resp, err := c.binanceService.GetPing()
+	resp, err := c.binanceService.GetPing(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in Ping: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "Ping failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // ServerTime handles the /api/v3/time endpoint.
 func (c *binanceSpotController) ServerTime(ctx *gin.Context) {
-	resp, err := c.binanceService.GetServerTime()
+	resp, err := c.binanceService.GetServerTime(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in ServerTime: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "ServerTime failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // ExchangeInfo handles the /api/v3/exchangeInfo endpoint.
 func (c *binanceSpotController) ExchangeInfo(ctx *gin.Context) {
-	resp, err := c.binanceService.GetExchangeInfo()
+	resp, err := c.binanceService.GetExchangeInfo(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in ExchangeInfo: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "ExchangeInfo failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // TickerPrice handles the /api/v3/ticker/price endpoint for a single symbol.
 func (c *binanceSpotController) TickerPrice(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
-	resp, err := c.binanceService.GetTickerPrice(symbol)
+	resp, err := c.binanceService.GetTickerPrice(ctx.Request.Context(), symbol)
 	if err != nil {
-		log.Printf("Error in TickerPrice for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "TickerPrice failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // AllPrices handles the /api/v3/ticker/price endpoint for all symbols.
 func (c *binanceSpotController) AllPrices(ctx *gin.Context) {
-	resp, err := c.binanceService.GetAllTickerPrices()
+	resp, err := c.binanceService.GetAllTickerPrices(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in AllPrices: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "AllPrices failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // BookTicker handles the /api/v3/ticker/bookTicker endpoint for a single symbol.
 func (c *binanceSpotController) BookTicker(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
-	resp, err := c.binanceService.GetBookTicker(symbol)
+	resp, err := c.binanceService.GetBookTicker(ctx.Request.Context(), symbol)
 	if err != nil {
-		log.Printf("Error in BookTicker for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "BookTicker failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // Depth handles the /api/v3/depth endpoint.
 func (c *binanceSpotController) Depth(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
 	limitStr := ctx.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
-	resp, err := c.binanceService.GetDepth(symbol, limit)
+	resp, err := c.binanceService.GetDepth(ctx.Request.Context(), symbol, limit)
 	if err != nil {
-		log.Printf("Error in Depth for symbol %s, limit %d: %v", symbol, limit, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "Depth failed", "symbol", symbol, "limit", limit, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // RecentTrades handles the /api/v3/trades endpoint.
 func (c *binanceSpotController) RecentTrades(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
 	limitStr := ctx.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
-	resp, err := c.binanceService.GetRecentTrades(symbol, limit)
+	resp, err := c.binanceService.GetRecentTrades(ctx.Request.Context(), symbol, limit)
 	if err != nil {
-		log.Printf("Error in RecentTrades for symbol %s, limit %d: %v", symbol, limit, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "RecentTrades failed", "symbol", symbol, "limit", limit, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // Klines handles the /api/v3/klines endpoint.
@@ -164,36 +163,36 @@ func (c *binanceSpotController) Klines(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	interval := ctx.Query("interval")
 	if symbol == "" || interval == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol and interval query parameters are required"})
+		respondBadRequest(ctx, "symbol and interval query parameters are required")
 		return
 	}
 	limitStr := ctx.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
-	resp, err := c.binanceService.GetKlines(symbol, interval, limit)
+	resp, err := c.binanceService.GetKlines(ctx.Request.Context(), symbol, interval, limit)
 	if err != nil {
-		log.Printf("Error in Klines for symbol %s, interval %s, limit %d: %v", symbol, interval, limit, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "Klines failed", "symbol", symbol, "interval", interval, "limit", limit, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // HistoricalTrades handles the /api/v3/historicalTrades endpoint.
 func (c *binanceSpotController) HistoricalTrades(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
 	limitStr := ctx.DefaultQuery("limit", "500") // Default limit for historical trades
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+		respondBadRequest(ctx, "invalid limit parameter")
 		return
 	}
 
@@ -202,26 +201,26 @@ func (c *binanceSpotController) HistoricalTrades(ctx *gin.Context) {
 	if fromIdStr != "" {
 		id, err := strconv.ParseInt(fromIdStr, 10, 64)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid fromId parameter"})
+			respondBadRequest(ctx, "invalid fromId parameter")
 			return
 		}
 		fromId = &id
 	}
 
-	resp, err := c.binanceService.GetHistoricalTrades(symbol, limit, fromId)
+	resp, err := c.binanceService.GetHistoricalTrades(ctx.Request.Context(), symbol, limit, fromId)
 	if err != nil {
-		log.Printf("Error in HistoricalTrades for symbol %s, limit %d, fromId %v: %v", symbol, limit, fromId, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "HistoricalTrades failed", "symbol", symbol, "limit", limit, "fromId", fromIdStr, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // AggregateTrades handles the /api/v3/aggTrades endpoint.
 func (c *binanceSpotController) AggregateTrades(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
 
@@ -231,7 +230,7 @@ func (c *binanceSpotController) AggregateTrades(ctx *gin.Context) {
 	if s := ctx.Query("fromId"); s != "" {
 		id, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid fromId parameter"})
+			respondBadRequest(ctx, "invalid fromId parameter")
 			return
 		}
 		fromId = &id
@@ -239,7 +238,7 @@ func (c *binanceSpotController) AggregateTrades(ctx *gin.Context) {
 	if s := ctx.Query("startTime"); s != "" {
 		t, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid startTime parameter"})
+			respondBadRequest(ctx, "invalid startTime parameter")
 			return
 		}
 		startTime = &t
@@ -247,7 +246,7 @@ func (c *binanceSpotController) AggregateTrades(ctx *gin.Context) {
 	if s := ctx.Query("endTime"); s != "" {
 		t, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid endTime parameter"})
+			respondBadRequest(ctx, "invalid endTime parameter")
 			return
 		}
 		endTime = &t
@@ -255,60 +254,60 @@ func (c *binanceSpotController) AggregateTrades(ctx *gin.Context) {
 	if s := ctx.Query("limit"); s != "" {
 		l, err := strconv.Atoi(s)
 		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit parameter"})
+			respondBadRequest(ctx, "invalid limit parameter")
 			return
 		}
 		limit = l
 	}
 
-	resp, err := c.binanceService.GetAggregateTrades(symbol, fromId, startTime, endTime, limit)
+	resp, err := c.binanceService.GetAggregateTrades(ctx.Request.Context(), symbol, fromId, startTime, endTime, limit)
 	if err != nil {
-		log.Printf("Error in AggregateTrades for symbol %s, fromId %v, startTime %v, endTime %v, limit %d: %v", symbol, fromId, startTime, endTime, limit, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "AggregateTrades failed", "symbol", symbol, "fromId", ctx.Query("fromId"), "startTime", ctx.Query("startTime"), "endTime", ctx.Query("endTime"), "limit", limit, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // AvgPrice handles the /api/v3/avgPrice endpoint.
 func (c *binanceSpotController) AvgPrice(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
-	resp, err := c.binanceService.GetAvgPrice(symbol)
+	resp, err := c.binanceService.GetAvgPrice(ctx.Request.Context(), symbol)
 	if err != nil {
-		log.Printf("Error in AvgPrice for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "AvgPrice failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // Ticker24Hr handles the /api/v3/ticker/24hr endpoint.
 func (c *binanceSpotController) Ticker24Hr(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	if symbol == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "symbol query parameter is required"})
+		respondBadRequest(ctx, "symbol query parameter is required")
 		return
 	}
-	resp, err := c.binanceService.GetTicker24Hr(symbol)
+	resp, err := c.binanceService.GetTicker24Hr(ctx.Request.Context(), symbol)
 	if err != nil {
-		log.Printf("Error in Ticker24Hr for symbol %s: %v", symbol, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "Ticker24Hr failed", "symbol", symbol, "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
 
 // AllBookTickers handles the /api/v3/ticker/bookTicker endpoint for all symbols.
 func (c *binanceSpotController) AllBookTickers(ctx *gin.Context) {
-	resp, err := c.binanceService.GetAllBookTickers()
+	resp, err := c.binanceService.GetAllBookTickers(ctx.Request.Context())
 	if err != nil {
-		log.Printf("Error in AllBookTickers: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		slog.ErrorContext(ctx.Request.Context(), "AllBookTickers failed", "error", err)
+		respondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, resp)
+	respondOK(ctx, resp)
 }
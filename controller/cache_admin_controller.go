@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+type CacheAdminController interface {
+	Keys(ctx *gin.Context)
+	Invalidate(ctx *gin.Context)
+	Refresh(ctx *gin.Context)
+	Stats(ctx *gin.Context)
+}
+
+type cacheAdminController struct {
+	admin service.CacheAdmin
+}
+
+// NewCacheAdminController creates and returns a new CacheAdminController instance.
+func NewCacheAdminController(admin service.CacheAdmin) CacheAdminController {
+	return &cacheAdminController{
+		admin: admin,
+	}
+}
+
+// Keys handles the /admin/cache/keys endpoint: the keys starting with the optional prefix.
+func (c *cacheAdminController) Keys(ctx *gin.Context) {
+	keys := c.admin.Keys(ctx.Query("prefix"))
+	ctx.JSON(http.StatusOK, gin.H{"count": len(keys), "keys": keys})
+}
+
+// Invalidate handles the /admin/cache/invalidate endpoint. Exactly one of the key,
+// prefix and symbol query parameters selects what is removed.
+func (c *cacheAdminController) Invalidate(ctx *gin.Context) {
+	key, prefix, symbol := ctx.Query("key"), ctx.Query("prefix"), ctx.Query("symbol")
+	var removed int
+	switch {
+	case key != "" && prefix == "" && symbol == "":
+		removed = c.admin.Invalidate(key)
+	case prefix != "" && key == "" && symbol == "":
+		removed = c.admin.InvalidatePrefix(prefix)
+	case symbol != "" && key == "" && prefix == "":
+		removed = c.admin.InvalidateSymbol(symbol)
+	default:
+		respondBadRequest(ctx, "Exactly one of key, prefix or symbol is required")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"invalidated": removed})
+}
+
+// Refresh handles the /admin/cache/refresh endpoint: it fetches key from the upstream now.
+func (c *cacheAdminController) Refresh(ctx *gin.Context) {
+	key := ctx.Query("key")
+	if key == "" {
+		respondBadRequest(ctx, "Key is required")
+		return
+	}
+	if err := c.admin.Refresh(ctx.Request.Context(), key); err != nil {
+		if errors.Is(err, service.ErrUnknownCacheKey) {
+			ctx.JSON(http.StatusNotFound, ErrorResponse{Error: "Key has not been served recently and cannot be refreshed", Code: ErrCodeNotFound})
+			return
+		}
+		respondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"refreshed": key})
+}
+
+// Stats handles the /admin/cache/stats endpoint: lookup statistics per cache name.
+func (c *cacheAdminController) Stats(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.admin.Stats())
+}
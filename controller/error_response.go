@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+// Error codes returned in the "code" field of the error envelope.
+const (
+	ErrCodeInvalidParameter        = "INVALID_PARAMETER"
+	ErrCodeNotFound                = "NOT_FOUND"
+	ErrCodeUpstreamRejected        = "UPSTREAM_REJECTED"
+	ErrCodeUpstreamRateLimited     = "UPSTREAM_RATE_LIMITED"
+	ErrCodeRateLimited             = "RATE_LIMITED"
+	ErrCodeUpstreamError           = "UPSTREAM_ERROR"
+	ErrCodeUpstreamUnavailable     = "UPSTREAM_UNAVAILABLE"
+	ErrCodeUpstreamTimeout         = "UPSTREAM_TIMEOUT"
+	ErrCodeInvalidUpstreamResponse = "INVALID_UPSTREAM_RESPONSE"
+	ErrCodeClientClosedRequest     = "CLIENT_CLOSED_REQUEST"
+	ErrCodeInternal                = "INTERNAL_ERROR"
+)
+
+// statusClientClosedRequest is the non-standard status used when the client went away.
+const statusClientClosedRequest = 499
+
+// ErrorResponse is the JSON error envelope returned by every endpoint. Error keeps the
+// human readable message; UpstreamCode and UpstreamStatus are only set when Binance
+// rejected the call.
+type ErrorResponse struct {
+	Error          string `json:"error"`
+	Code           string `json:"code"`
+	UpstreamCode   int    `json:"upstreamCode,omitempty"`
+	UpstreamStatus int    `json:"upstreamStatus,omitempty"`
+}
+
+// respondBadRequest answers a request whose parameters failed validation.
+func respondBadRequest(ctx *gin.Context, message string) {
+	ctx.JSON(http.StatusBadRequest, ErrorResponse{Error: message, Code: ErrCodeInvalidParameter})
+}
+
+// respondError translates a service error into the matching status and error envelope.
+func respondError(ctx *gin.Context, err error) {
+	status, resp := classifyError(err)
+	if retryAfter := retryAfterOf(err); retryAfter > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	ctx.JSON(status, resp)
+}
+
+// retryAfterOf returns how long the client should wait before retrying, if known.
+func retryAfterOf(err error) time.Duration {
+	var upstreamErr *service.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.RetryAfter
+	}
+	var rateLimitErr *service.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return rateLimitErr.RetryAfter
+	}
+	var circuitErr *service.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		return circuitErr.RetryAfter
+	}
+	return 0
+}
+
+func classifyError(err error) (int, ErrorResponse) {
+	var upstreamErr *service.UpstreamError
+	var rateLimitErr *service.RateLimitError
+	switch {
+	case errors.As(err, &rateLimitErr):
+		return http.StatusServiceUnavailable, ErrorResponse{Error: err.Error(), Code: ErrCodeRateLimited}
+	case errors.Is(err, service.ErrCircuitOpen):
+		return http.StatusServiceUnavailable, ErrorResponse{Error: err.Error(), Code: ErrCodeUpstreamUnavailable}
+	case errors.As(err, &upstreamErr):
+		resp := ErrorResponse{
+			Error:          upstreamErr.Msg,
+			UpstreamCode:   upstreamErr.Code,
+			UpstreamStatus: upstreamErr.StatusCode,
+		}
+		switch {
+		case upstreamErr.IsClientError():
+			resp.Code = ErrCodeUpstreamRejected
+			return http.StatusBadRequest, resp
+		case upstreamErr.IsRateLimited():
+			resp.Code = ErrCodeUpstreamRateLimited
+			return http.StatusServiceUnavailable, resp
+		case upstreamErr.StatusCode == http.StatusServiceUnavailable:
+			resp.Code = ErrCodeUpstreamUnavailable
+			return http.StatusServiceUnavailable, resp
+		default:
+			resp.Code = ErrCodeUpstreamError
+			resp.Error = fmt.Sprintf("binance %s API error: %s", upstreamErr.Upstream, upstreamErr.Msg)
+			return http.StatusBadGateway, resp
+		}
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest, ErrorResponse{Error: err.Error(), Code: ErrCodeClientClosedRequest}
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, ErrorResponse{Error: err.Error(), Code: ErrCodeUpstreamTimeout}
+	case errors.Is(err, service.ErrUpstreamUnreachable):
+		return http.StatusBadGateway, ErrorResponse{Error: err.Error(), Code: ErrCodeUpstreamUnavailable}
+	case errors.Is(err, service.ErrInvalidUpstreamResponse):
+		return http.StatusBadGateway, ErrorResponse{Error: err.Error(), Code: ErrCodeInvalidUpstreamResponse}
+	default:
+		return http.StatusInternalServerError, ErrorResponse{Error: err.Error(), Code: ErrCodeInternal}
+	}
+}
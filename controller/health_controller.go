@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+type HealthController interface {
+	Healthz(ctx *gin.Context)
+	Readyz(ctx *gin.Context)
+	Status(ctx *gin.Context)
+}
+
+type healthController struct {
+	checker service.HealthChecker
+}
+
+// NewHealthController creates and returns a new HealthController instance.
+func NewHealthController(checker service.HealthChecker) HealthController {
+	return &healthController{
+		checker: checker,
+	}
+}
+
+// Healthz handles the /healthz liveness endpoint. It succeeds as long as the process
+// serves requests.
+func (c *healthController) Healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz handles the /readyz readiness endpoint, answering 503 while any check fails.
+func (c *healthController) Readyz(ctx *gin.Context) {
+	readiness := c.checker.Ready()
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, readiness)
+}
+
+// Status handles the /status endpoint with the readiness checks and the state of
+// every upstream.
+func (c *healthController) Status(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.checker.Status())
+}
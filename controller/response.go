@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+// respondOK writes resp along with the X-Cache and Age headers describing how the
+// cached lookup behind it was served, and the ETag and Cache-Control headers that let
+// clients and CDNs revalidate it. A request whose If-None-Match matches the ETag gets
+// 304 Not Modified. Responses marked private, e.g. those metered per API key, are only
+// cacheable by the client. When the request collected a pass-through response, its raw body is
+// written instead of resp.
+func respondOK(ctx *gin.Context, resp interface{}) {
+	if result, ok := service.CacheResultFromContext(ctx.Request.Context()); ok {
+		if status, age := result.Status(); status != "" {
+			ageSeconds := int(age.Seconds())
+			ctx.Header("X-Cache", status)
+			ctx.Header("Age", strconv.Itoa(ageSeconds))
+			if etag, expireTime := result.Validator(); etag != "" {
+				// Caches subtract Age from max-age, so it is added back to make the
+				// freshness they compute the remaining TTL of the entry.
+				remaining := max(int(time.Until(expireTime).Seconds()), 0)
+				ctx.Header("ETag", etag)
+				visibility := "public"
+				if service.IsPrivateResponse(ctx.Request.Context()) {
+					visibility = "private"
+				}
+				ctx.Header("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, ageSeconds+remaining))
+				if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+					ctx.Status(http.StatusNotModified)
+					return
+				}
+			}
+		}
+	}
+	if passthrough, ok := service.PassthroughFromContext(ctx.Request.Context()); ok {
+		if raw, ok := passthrough.Response(); ok {
+			respondRaw(ctx, raw)
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// respondRaw writes an upstream body as it was received, gzip-compressed if a gzip
+// copy was prepared and the client accepts it.
+func respondRaw(ctx *gin.Context, raw *service.RawResponse) {
+	const contentType = "application/json; charset=utf-8"
+	gzipped, ok := raw.Gzipped()
+	if !ok {
+		ctx.Data(http.StatusOK, contentType, raw.Body())
+		return
+	}
+	ctx.Header("Vary", "Accept-Encoding")
+	if !acceptsGzip(ctx.GetHeader("Accept-Encoding")) {
+		ctx.Data(http.StatusOK, contentType, raw.Body())
+		return
+	}
+	ctx.Header("Content-Encoding", "gzip")
+	ctx.Data(http.StatusOK, contentType, gzipped)
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip, either by name or
+// through "*", without a zero quality.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if quality, err := strconv.ParseFloat(q, 64); err == nil && quality == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing tags weakly
+// as RFC 9110 requires for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/config"
+	"github.com/ntdat104/go-crypto/middleware"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+// benchmarkSymbols is about the number of symbols listed by the all-symbol endpoints.
+const benchmarkSymbols = 2500
+
+// BenchmarkAllPrices and BenchmarkAll24hrTickers compare serving a cached all-symbol
+// response through the typed models, which are decoded on the first hit and re-encoded
+// on every hit, with writing the upstream body as it was received.
+func BenchmarkAllPrices(b *testing.B) {
+	benchmarkResponse(b, "/api/crypto/ticker/allPrices")
+}
+
+func BenchmarkAll24hrTickers(b *testing.B) {
+	benchmarkResponse(b, "/api/crypto/futures/all24hrTickers")
+}
+
+func benchmarkResponse(b *testing.B, path string) {
+	upstream := httptest.NewServer(http.HandlerFunc(fakeAllSymbols))
+	defer upstream.Close()
+
+	for _, bench := range []struct {
+		name        string
+		passthrough bool
+		acceptGzip  bool
+	}{
+		{name: "typed"},
+		{name: "passthrough", passthrough: true},
+		{name: "passthrough-gzip", passthrough: true, acceptGzip: true},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			router := newBenchmarkRouter(b, upstream.URL, bench.passthrough)
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if bench.acceptGzip {
+				req.Header.Set("Accept-Encoding", "gzip")
+			}
+			// Fill the cache, and decode the typed models, before measuring hits.
+			for i := 0; i < 2; i++ {
+				if rec := serve(router, req); rec.Code != http.StatusOK {
+					b.Fatalf("status %d: %s", rec.Code, rec.Body)
+				}
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rec := serve(router, req)
+				if rec.Code != http.StatusOK {
+					b.Fatalf("status %d", rec.Code)
+				}
+				b.SetBytes(int64(rec.Body.Len()))
+			}
+		})
+	}
+}
+
+func serve(router http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// newBenchmarkRouter wires the spot and futures controllers to upstreams at baseURL the
+// way main does, without the background jobs.
+func newBenchmarkRouter(b *testing.B, baseURL string, passthrough bool) http.Handler {
+	b.Helper()
+	cfg := config.Default()
+	cfg.Spot.BaseURL = baseURL
+	cfg.Futures.BaseURL = baseURL
+	cfg.Cache.Passthrough.Enabled = passthrough
+	cfg.Warm.Keys = nil
+
+	cache, err := service.NewCacheBackend(cfg.Cache)
+	if err != nil {
+		b.Fatal(err)
+	}
+	refresher := service.NewBackgroundRefresher(cfg.Cache.RefreshConcurrency)
+	governor := service.NewRateLimitGovernor()
+	breakers := service.NewCircuitBreakerRegistry()
+	admin := service.NewCacheAdmin(cache, cfg.Cache.MaxEntries)
+	deps := service.UpstreamDependencies{
+		Cache:       cache,
+		Coalescer:   service.NewRequestCoalescer(),
+		Refresher:   refresher,
+		HTTPClient:  service.NewHTTPClient(cfg.HTTP),
+		Governor:    governor,
+		Breakers:    breakers,
+		Admin:       admin,
+		Scheduler:   service.NewRefreshScheduler(cfg.Warm, cache, refresher, governor, cfg.Cache.MaxEntries),
+		Passthrough: cfg.Cache.Passthrough,
+		Containment: cfg.Cache.Containment,
+		Clock:       service.NewUpstreamClock(cfg.Clock),
+		Monitor:     service.NewUpstreamMonitor(cfg.Health.ErrorWindow.Duration()),
+		Metrics:     service.NewMetrics(cache, admin, refresher, governor),
+	}
+	spot := NewBinanceSpotController(service.NewBinanceSpotService(deps, cfg.Spot))
+	futures := NewBinanceFutureController(service.NewBinanceFuturesService(deps, cfg.Futures))
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	api := router.Group("/api/crypto")
+	api.Use(middleware.CacheResult())
+	if passthrough {
+		api.Use(middleware.Passthrough())
+	}
+	api.GET("/ticker/allPrices", spot.AllPrices)
+	api.GET("/futures/all24hrTickers", futures.FuturesAll24HrTickers)
+	return router
+}
+
+// fakeAllSymbols answers the all-symbol price and 24 hour ticker endpoints of both
+// upstreams with benchmarkSymbols entries.
+func fakeAllSymbols(w http.ResponseWriter, r *http.Request) {
+	items := make([]map[string]interface{}, benchmarkSymbols)
+	for i := range items {
+		symbol := fmt.Sprintf("SYM%04dUSDT", i)
+		switch r.URL.Path {
+		case "/api/v3/ticker/price":
+			items[i] = map[string]interface{}{"symbol": symbol, "price": "65000.12000000"}
+		case "/fapi/v1/ticker/24hr":
+			items[i] = map[string]interface{}{
+				"symbol": symbol, "priceChange": "-94.99999800", "priceChangePercent": "-95.960",
+				"weightedAvgPrice": "0.29628482", "lastPrice": "4.00000200", "lastQty": "200.00000000",
+				"openPrice": "99.00000000", "highPrice": "100.00000000", "lowPrice": "0.10000000",
+				"volume": "8913.30000000", "quoteVolume": "15.30000000", "openTime": 1499783499040,
+				"closeTime": 1499869899040, "firstId": 28385, "lastId": 28460, "count": 76,
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
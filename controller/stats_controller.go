@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+type StatsController interface {
+	Coalescing(ctx *gin.Context)
+	Refresh(ctx *gin.Context)
+	RateLimit(ctx *gin.Context)
+	CircuitBreakers(ctx *gin.Context)
+	Cache(ctx *gin.Context)
+	Scheduler(ctx *gin.Context)
+	Clock(ctx *gin.Context)
+}
+
+type statsController struct {
+	coalescer service.RequestCoalescer
+	refresher service.BackgroundRefresher
+	governor  service.RateLimitGovernor
+	breakers  service.CircuitBreakerRegistry
+	cache     service.CacheBackend
+	scheduler service.RefreshScheduler
+	clock     service.UpstreamClock
+}
+
+// NewStatsController creates and returns a new StatsController instance.
+func NewStatsController(coalescer service.RequestCoalescer, refresher service.BackgroundRefresher, governor service.RateLimitGovernor, breakers service.CircuitBreakerRegistry, cache service.CacheBackend, scheduler service.RefreshScheduler, clock service.UpstreamClock) StatsController {
+	return &statsController{
+		coalescer: coalescer,
+		refresher: refresher,
+		governor:  governor,
+		breakers:  breakers,
+		cache:     cache,
+		scheduler: scheduler,
+		clock:     clock,
+	}
+}
+
+// Coalescing handles the /stats/coalescing endpoint.
+func (c *statsController) Coalescing(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.coalescer.Stats())
+}
+
+// Refresh handles the /stats/refresh endpoint.
+func (c *statsController) Refresh(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.refresher.Stats())
+}
+
+// RateLimit handles the /stats/rateLimit endpoint.
+func (c *statsController) RateLimit(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.governor.Stats())
+}
+
+// CircuitBreakers handles the /stats/circuitBreakers endpoint.
+func (c *statsController) CircuitBreakers(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.breakers.States())
+}
+
+// Cache handles the /stats/cache endpoint.
+func (c *statsController) Cache(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.cache.Stats())
+}
+
+// Scheduler handles the /stats/scheduler endpoint.
+func (c *statsController) Scheduler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.scheduler.Stats())
+}
+
+// Clock handles the /stats/clock endpoint.
+func (c *statsController) Clock(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.clock.Stats())
+}
@@ -1,90 +1,237 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/config"
 	"github.com/ntdat104/go-crypto/controller" // Assuming this path is correct
-	"github.com/ntdat104/go-crypto/service"    // Assuming this path is correct
+	"github.com/ntdat104/go-crypto/middleware"
+	"github.com/ntdat104/go-crypto/service" // Assuming this path is correct
 )
 
 func main() {
-	// Initialize local cache service
-	localCacheService := service.NewLocalCacheService()
+	// Load configuration from the optional config file and the environment
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	// Log structured records in the configured format, tagged with the request ID
+	logger, err := service.NewLogger(cfg.Log, os.Stderr)
+	if err != nil {
+		fatal("Invalid log configuration", err)
+	}
+	slog.SetDefault(logger)
+
+	// Export traces of the API requests and upstream calls
+	shutdownTracing, err := service.SetupTracing(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Invalid tracing configuration", err)
+	}
+
+	// Initialize the cache backend: in-memory, shared Redis, or both
+	cacheBackend, err := service.NewCacheBackend(cfg.Cache)
+	if err != nil {
+		fatal("Invalid cache configuration", err)
+	}
+
+	// Warm the cache from the snapshot written by the previous run
+	cacheSnapshotter := service.NewCacheSnapshotter(cacheBackend, cfg.Cache.Snapshot)
+	if err := cacheSnapshotter.Restore(); err != nil {
+		slog.Warn("Starting with an empty cache", "error", err)
+	}
+
+	// Share in-flight upstream calls between concurrent cache misses
+	requestCoalescer := service.NewRequestCoalescer()
+
+	// Refresh cache entries in the background, one refresh per key at a time
+	backgroundRefresher := service.NewBackgroundRefresher(cfg.Cache.RefreshConcurrency)
+
+	// Keep upstream calls under the Binance request-weight limits
+	rateLimitGovernor := service.NewRateLimitGovernor()
+	circuitBreakers := service.NewCircuitBreakerRegistry()
+	// Pre-warm the warm list and keep hot keys fresh in the background
+	refreshScheduler := service.NewRefreshScheduler(cfg.Warm, cacheBackend, backgroundRefresher, rateLimitGovernor, cfg.Cache.MaxEntries)
+
+	// Measure upstream latency and the offset between our clock and the exchange clocks
+	upstreamClock := service.NewUpstreamClock(cfg.Clock)
+	// Record the outcome of every upstream call for the readiness checks
+	upstreamMonitor := service.NewUpstreamMonitor(cfg.Health.ErrorWindow.Duration())
+	healthChecker := service.NewHealthChecker(cfg.Health, refreshScheduler, circuitBreakers, rateLimitGovernor, upstreamMonitor, upstreamClock)
+	healthController := controller.NewHealthController(healthChecker)
+
+	// Track cache keys for the admin endpoints
+	cacheAdmin := service.NewCacheAdmin(cacheBackend, cfg.Cache.MaxEntries)
+	cacheAdminController := controller.NewCacheAdminController(cacheAdmin)
+	// Export the request, upstream and cache metrics to Prometheus
+	metrics := service.NewMetrics(cacheBackend, cacheAdmin, backgroundRefresher, rateLimitGovernor)
+	statsController := controller.NewStatsController(requestCoalescer, backgroundRefresher, rateLimitGovernor, circuitBreakers, cacheBackend, refreshScheduler, upstreamClock)
+
+	// Authenticate and rate limit the API clients
+	apiKeyAuth := service.NewAPIKeyAuth(cfg.Auth)
+	apiKeyController := controller.NewAPIKeyController(apiKeyAuth)
+
+	// Collaborators shared by the Spot and Futures services
+	upstreamDependencies := service.UpstreamDependencies{
+		Cache:       cacheBackend,
+		Coalescer:   requestCoalescer,
+		Refresher:   backgroundRefresher,
+		HTTPClient:  service.NewHTTPClient(cfg.HTTP),
+		Governor:    rateLimitGovernor,
+		Breakers:    circuitBreakers,
+		Admin:       cacheAdmin,
+		Scheduler:   refreshScheduler,
+		Passthrough: cfg.Cache.Passthrough,
+		Containment: cfg.Cache.Containment,
+		Clock:       upstreamClock,
+		Monitor:     upstreamMonitor,
+		Metrics:     metrics,
+	}
 
 	// Initialize Binance Spot Service and Controller
-	binanceSpotService := service.NewBinanceSpotService(localCacheService)           // Assuming this is your Spot service
-	binanceSpotController := controller.NewBinanceSpotController(binanceSpotService) // Assuming this is your Spot controller
+	binanceSpotService := service.NewBinanceSpotService(upstreamDependencies, cfg.Spot) // Assuming this is your Spot service
+	binanceSpotController := controller.NewBinanceSpotController(binanceSpotService)    // Assuming this is your Spot controller
 
 	// Initialize Binance Futures Service and Controller
-	binanceFuturesService := service.NewBinanceFuturesService(localCacheService)            // Assuming this is your Futures service
-	binanceFutureController := controller.NewBinanceFutureController(binanceFuturesService) // Assuming this is your Futures controller
+	binanceFuturesService := service.NewBinanceFuturesService(upstreamDependencies, cfg.Futures) // Assuming this is your Futures service
+	binanceFutureController := controller.NewBinanceFutureController(binanceFuturesService)      // Assuming this is your Futures controller
 
 	gin.SetMode(gin.ReleaseMode) // Set Gin to release mode for production
-	router := gin.Default()      // Create a new Gin router (without default middleware)
-
-	// Allow all CORS
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-
-		// Respond to OPTIONS requests and stop further processing
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	router := gin.New()          // Create a new Gin router (without default middleware)
+	router.Use(gin.Recovery())
+
+	// Only believe X-Forwarded-For from the configured proxies, so clients cannot pick
+	// their own IP and escape the per-IP limits
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		fatal("Invalid trusted proxies", err)
+	}
+
+	// Tag every request with an ID and log it once it has been served
+	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestLog())
+
+	// Apply the CORS policy and answer preflight requests for every route
+	router.Use(middleware.CORS(cfg.CORS))
+
+	// Liveness and readiness probes for the load balancer
+	router.GET("/healthz", healthController.Healthz)
+	router.GET("/readyz", healthController.Readyz)
+
+	// Prometheus scrape endpoint
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	// Define API routes
 	apiGroup := router.Group("/api/crypto")
+	apiGroup.Use(middleware.Metrics(metrics))
+	apiGroup.Use(middleware.Tracing())
+	apiGroup.Use(middleware.CacheResult())
+	if cfg.Cache.Passthrough.Enabled {
+		// Write cached upstream bodies as they were received
+		apiGroup.Use(middleware.Passthrough())
+	}
+	// The Binance data routes spend our upstream weight, so they are the ones that
+	// require an API key
+	binanceGroup := apiGroup.Group("")
+	if cfg.Auth.Enabled {
+		binanceGroup.Use(middleware.APIKey(apiKeyAuth, cfg.Auth.Header))
+	}
 	{
 		// Binance Spot Endpoints
-		apiGroup.GET("/ping", binanceSpotController.Ping)
-		apiGroup.GET("/time", binanceSpotController.ServerTime)
-		apiGroup.GET("/exchangeInfo", binanceSpotController.ExchangeInfo)
-		apiGroup.GET("/ticker/price", binanceSpotController.TickerPrice)
-		apiGroup.GET("/ticker/allPrices", binanceSpotController.AllPrices)
-		apiGroup.GET("/bookTicker", binanceSpotController.BookTicker)
-		apiGroup.GET("/depth", binanceSpotController.Depth)
-		apiGroup.GET("/trades", binanceSpotController.RecentTrades)
-		apiGroup.GET("/klines", binanceSpotController.Klines)
-		apiGroup.GET("/historicalTrades", binanceSpotController.HistoricalTrades) // Added
-		apiGroup.GET("/aggregateTrades", binanceSpotController.AggregateTrades)   // Added
-		apiGroup.GET("/avgPrice", binanceSpotController.AvgPrice)                 // Added
-		apiGroup.GET("/ticker/24hr", binanceSpotController.Ticker24Hr)            // Added
-		apiGroup.GET("/bookTicker/all", binanceSpotController.AllBookTickers)     // Added
+		binanceGroup.GET("/ping", binanceSpotController.Ping)
+		binanceGroup.GET("/time", binanceSpotController.ServerTime)
+		binanceGroup.GET("/exchangeInfo", binanceSpotController.ExchangeInfo)
+		binanceGroup.GET("/ticker/price", binanceSpotController.TickerPrice)
+		binanceGroup.GET("/ticker/allPrices", binanceSpotController.AllPrices)
+		binanceGroup.GET("/bookTicker", binanceSpotController.BookTicker)
+		binanceGroup.GET("/depth", binanceSpotController.Depth)
+		binanceGroup.GET("/trades", binanceSpotController.RecentTrades)
+		binanceGroup.GET("/klines", binanceSpotController.Klines)
+		binanceGroup.GET("/historicalTrades", binanceSpotController.HistoricalTrades) // Added
+		binanceGroup.GET("/aggregateTrades", binanceSpotController.AggregateTrades)   // Added
+		binanceGroup.GET("/avgPrice", binanceSpotController.AvgPrice)                 // Added
+		binanceGroup.GET("/ticker/24hr", binanceSpotController.Ticker24Hr)            // Added
+		binanceGroup.GET("/bookTicker/all", binanceSpotController.AllBookTickers)     // Added
 
 		// Binance Futures Endpoints
-		apiGroup.GET("/futures/ping", binanceFutureController.FuturesPing)
-		apiGroup.GET("/futures/time", binanceFutureController.FuturesTime)
-		apiGroup.GET("/futures/exchangeInfo", binanceFutureController.FuturesExchangeInfo)
-		apiGroup.GET("/futures/depth", binanceFutureController.FuturesDepth)
-		apiGroup.GET("/futures/aggTrades", binanceFutureController.FuturesAggTrades)
-		apiGroup.GET("/futures/ticker/price", binanceFutureController.FuturesTickerPrice)
-		apiGroup.GET("/futures/ticker/allPrices", binanceFutureController.FuturesAllTickerPrices)
-		apiGroup.GET("/futures/bookTicker", binanceFutureController.FuturesBookTicker)
-		apiGroup.GET("/futures/klines", binanceFutureController.FuturesKlines)
-		apiGroup.GET("/futures/markPrice", binanceFutureController.FuturesMarkPrice)
-		apiGroup.GET("/futures/allForceOrders", binanceFutureController.FuturesAllForceOrders)
-		apiGroup.GET("/futures/24hrTicker", binanceFutureController.Futures24HrTicker)
-		apiGroup.GET("/futures/all24hrTickers", binanceFutureController.FuturesAll24HrTickers)
-		apiGroup.GET("/futures/fundingRate", binanceFutureController.FuturesFundingRate)
-		apiGroup.GET("/futures/recentTrades", binanceFutureController.FuturesRecentTrades)
-	}
+		binanceGroup.GET("/futures/ping", binanceFutureController.FuturesPing)
+		binanceGroup.GET("/futures/time", binanceFutureController.FuturesTime)
+		binanceGroup.GET("/futures/exchangeInfo", binanceFutureController.FuturesExchangeInfo)
+		binanceGroup.GET("/futures/depth", binanceFutureController.FuturesDepth)
+		binanceGroup.GET("/futures/aggTrades", binanceFutureController.FuturesAggTrades)
+		binanceGroup.GET("/futures/ticker/price", binanceFutureController.FuturesTickerPrice)
+		binanceGroup.GET("/futures/ticker/allPrices", binanceFutureController.FuturesAllTickerPrices)
+		binanceGroup.GET("/futures/bookTicker", binanceFutureController.FuturesBookTicker)
+		binanceGroup.GET("/futures/klines", binanceFutureController.FuturesKlines)
+		binanceGroup.GET("/futures/markPrice", binanceFutureController.FuturesMarkPrice)
+		binanceGroup.GET("/futures/allForceOrders", binanceFutureController.FuturesAllForceOrders)
+		binanceGroup.GET("/futures/24hrTicker", binanceFutureController.Futures24HrTicker)
+		binanceGroup.GET("/futures/all24hrTickers", binanceFutureController.FuturesAll24HrTickers)
+		binanceGroup.GET("/futures/fundingRate", binanceFutureController.FuturesFundingRate)
+		binanceGroup.GET("/futures/recentTrades", binanceFutureController.FuturesRecentTrades)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+		// Stats Endpoints
+		apiGroup.GET("/stats/coalescing", statsController.Coalescing)
+		apiGroup.GET("/stats/refresh", statsController.Refresh)
+		apiGroup.GET("/stats/rateLimit", statsController.RateLimit)
+		apiGroup.GET("/stats/circuitBreakers", statsController.CircuitBreakers)
+		apiGroup.GET("/stats/cache", statsController.Cache)
+		apiGroup.GET("/stats/scheduler", statsController.Scheduler)
+		apiGroup.GET("/stats/clock", statsController.Clock)
+
+		// Status Endpoint
+		apiGroup.GET("/status", healthController.Status)
+
+		// Admin Endpoints, protected by the admin token
+		adminGroup := apiGroup.Group("/admin", middleware.AdminAuth(cfg.Admin.Token))
+		adminGroup.GET("/cache/keys", cacheAdminController.Keys)
+		adminGroup.POST("/cache/invalidate", cacheAdminController.Invalidate)
+		adminGroup.POST("/cache/refresh", cacheAdminController.Refresh)
+		adminGroup.GET("/cache/stats", cacheAdminController.Stats)
+		adminGroup.GET("/auth/usage", apiKeyController.Usage)
 	}
 
+	// Stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go cacheSnapshotter.Run(ctx)
+	go refreshScheduler.Warm(ctx, binanceSpotService, binanceFuturesService)
+	go refreshScheduler.Run(ctx)
+	go upstreamClock.Run(ctx, binanceSpotService, binanceFuturesService)
+
 	// Run the server
-	log.Println("Starting server on :" + port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	server := &http.Server{Addr: cfg.Server.Addr, Handler: router}
+	go func() {
+		slog.Info("Starting server", "addr", cfg.Server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Server failed to start", err)
+		}
+	}()
+	<-ctx.Done()
+
+	// Let in-flight requests finish, then keep the cache for the next run
+	slog.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration())
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown failed", "error", err)
 	}
+	if err := cacheSnapshotter.Save(); err != nil {
+		slog.Error("Failed to save cache snapshot", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
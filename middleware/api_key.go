@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+// APIKey only lets requests through that carry a valid API key in header, or no key
+// when anonymous requests are allowed, and that are within the limits of their client.
+// Every response reports the limits in X-RateLimit-* headers; the Reset headers are in
+// seconds from now. Responses are marked private, so shared caches do not serve them
+// past the limits of the client.
+func APIKey(auth service.APIKeyAuth, header string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		decision, err := auth.Allow(c.GetHeader(header), c.ClientIP())
+		if err != nil {
+			message := "invalid API key"
+			if errors.Is(err, service.ErrMissingAPIKey) {
+				message = "missing API key in the " + header + " header"
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message, "code": "UNAUTHORIZED"})
+			return
+		}
+
+		now := time.Now()
+		c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Header("X-RateLimit-Reset", secondsUntil(now, decision.Reset))
+		if decision.QuotaLimit > 0 {
+			c.Header("X-RateLimit-Quota-Limit", strconv.Itoa(decision.QuotaLimit))
+			c.Header("X-RateLimit-Quota-Remaining", strconv.Itoa(decision.QuotaRemaining))
+			c.Header("X-RateLimit-Quota-Reset", secondsUntil(now, decision.QuotaReset))
+		}
+		if !decision.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+			if decision.QuotaExceeded {
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "daily quota exceeded", "code": "QUOTA_EXCEEDED"})
+			} else {
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests", "code": "TOO_MANY_REQUESTS"})
+			}
+			return
+		}
+		c.Request = c.Request.WithContext(service.WithPrivateResponse(c.Request.Context()))
+		c.Next()
+	}
+}
+
+func secondsUntil(now, t time.Time) string {
+	return strconv.Itoa(max(int(math.Ceil(t.Sub(now).Seconds())), 0))
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+// CacheResult installs a service.CacheResult on every request context, so the services
+// can record whether the response was a cache hit, miss or stale value.
+func CacheResult() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqCtx, _ := service.WithCacheResult(c.Request.Context())
+		c.Request = c.Request.WithContext(reqCtx)
+		c.Next()
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// CORS applies the cross-origin policy of cfg. Requests from an allowed origin get the
+// origin echoed back, or "*" when any origin is allowed without credentials, and
+// preflight requests are answered here for every route. Requests from other origins are
+// served without CORS headers, so browsers do not expose the response, and their
+// preflight requests are refused.
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	origins := newOriginMatcher(cfg.AllowedOrigins)
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Duration().Seconds()))
+	wildcard := origins.any && !cfg.AllowCredentials
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !wildcard {
+			// The response depends on the origin, so caches must keep one per origin.
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !origins.match(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if wildcard {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if exposed != "" {
+				c.Header("Access-Control-Expose-Headers", exposed)
+			}
+			c.Next()
+			return
+		}
+
+		if !wildcard {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+		c.Header("Access-Control-Allow-Methods", methods)
+		if headers != "" {
+			c.Header("Access-Control-Allow-Headers", headers)
+		}
+		if cfg.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// originMatcher matches origins against exact and wildcard subdomain patterns.
+type originMatcher struct {
+	any   bool
+	exact map[string]bool
+	// subdomains holds the scheme and parent domain of every wildcard pattern, e.g.
+	// "https://" and ".example.com" for "https://*.example.com".
+	subdomains [][2]string
+}
+
+func newOriginMatcher(patterns []string) *originMatcher {
+	m := &originMatcher{exact: make(map[string]bool, len(patterns))}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		switch {
+		case pattern == "*":
+			m.any = true
+		case strings.Contains(pattern, "://*."):
+			scheme, domain, _ := strings.Cut(pattern, "*")
+			m.subdomains = append(m.subdomains, [2]string{scheme, domain})
+		default:
+			m.exact[pattern] = true
+		}
+	}
+	return m
+}
+
+func (m *originMatcher) match(origin string) bool {
+	if m.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if m.exact[origin] {
+		return true
+	}
+	for _, subdomain := range m.subdomains {
+		scheme, domain := subdomain[0], subdomain[1]
+		if len(origin) <= len(scheme)+len(domain) || !strings.HasPrefix(origin, scheme) || !strings.HasSuffix(origin, domain) {
+			continue
+		}
+		if validHostLabels(origin[len(scheme) : len(origin)-len(domain)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// validHostLabels reports whether s is made of host name characters only, so that a
+// wildcard cannot match across a port, path or user info.
+func validHostLabels(s string) bool {
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if !(ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '.') {
+			return false
+		}
+	}
+	return true
+}
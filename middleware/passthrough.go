@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/ntdat104/go-crypto/service"
+)
+
+// Passthrough installs a service.Passthrough on every request context, so the cached
+// upstream bodies behind the response are written to the client as they were received.
+func Passthrough() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reqCtx, _ := service.WithPassthrough(c.Request.Context())
+		c.Request = c.Request.WithContext(reqCtx)
+		c.Next()
+	}
+}
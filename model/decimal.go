@@ -0,0 +1,73 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Decimal is a numeric value that Binance encodes as a JSON string (e.g. "27123.45000000").
+// The original text is kept so no precision is lost when the value is passed back to clients.
+type Decimal string
+
+// ParseDecimal validates s and returns it as a Decimal. An empty string is treated as zero.
+func ParseDecimal(s string) (Decimal, error) {
+	if s == "" {
+		return "", nil
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return "", fmt.Errorf("invalid decimal %q: %w", s, err)
+	}
+	return Decimal(s), nil
+}
+
+// UnmarshalJSON accepts both string-encoded and bare JSON numbers.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = ""
+		return nil
+	}
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalJSON always encodes the value as a string, matching the Binance wire format.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(d))
+}
+
+// String returns the original decimal text.
+func (d Decimal) String() string {
+	return string(d)
+}
+
+// Float64 returns the value as a float64. Values are validated on decode, so an
+// unparsable Decimal can only come from a direct conversion and yields 0.
+func (d Decimal) Float64() float64 {
+	if d == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(string(d), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// IsZero reports whether the value is empty or numerically zero.
+func (d Decimal) IsZero() bool {
+	return d.Float64() == 0
+}
@@ -0,0 +1,135 @@
+package model
+
+// Symbol filter types used by Binance.
+const (
+	FilterPrice          = "PRICE_FILTER"
+	FilterPercentPrice   = "PERCENT_PRICE"
+	FilterLotSize        = "LOT_SIZE"
+	FilterMarketLotSize  = "MARKET_LOT_SIZE"
+	FilterMinNotional    = "MIN_NOTIONAL"
+	FilterNotional       = "NOTIONAL"
+	FilterIcebergParts   = "ICEBERG_PARTS"
+	FilterMaxNumOrders   = "MAX_NUM_ORDERS"
+	FilterMaxAlgoOrders  = "MAX_NUM_ALGO_ORDERS"
+	FilterTrailingDelta  = "TRAILING_DELTA"
+	FilterPercentPriceBS = "PERCENT_PRICE_BY_SIDE"
+)
+
+// RateLimit describes one of the exchange's request or order rate limits.
+type RateLimit struct {
+	RateLimitType string `json:"rateLimitType"`
+	Interval      string `json:"interval"`
+	IntervalNum   int    `json:"intervalNum"`
+	Limit         int    `json:"limit"`
+}
+
+// SymbolFilter is a trading rule attached to a symbol. Only the fields relevant to
+// FilterType are set; the rest are omitted when encoding.
+type SymbolFilter struct {
+	FilterType string `json:"filterType"`
+
+	// PRICE_FILTER
+	MinPrice Decimal `json:"minPrice,omitempty"`
+	MaxPrice Decimal `json:"maxPrice,omitempty"`
+	TickSize Decimal `json:"tickSize,omitempty"`
+
+	// PERCENT_PRICE, PERCENT_PRICE_BY_SIDE
+	MultiplierUp      Decimal `json:"multiplierUp,omitempty"`
+	MultiplierDown    Decimal `json:"multiplierDown,omitempty"`
+	MultiplierDecimal Decimal `json:"multiplierDecimal,omitempty"`
+	BidMultiplierUp   Decimal `json:"bidMultiplierUp,omitempty"`
+	BidMultiplierDown Decimal `json:"bidMultiplierDown,omitempty"`
+	AskMultiplierUp   Decimal `json:"askMultiplierUp,omitempty"`
+	AskMultiplierDown Decimal `json:"askMultiplierDown,omitempty"`
+	AvgPriceMins      *int    `json:"avgPriceMins,omitempty"`
+
+	// LOT_SIZE, MARKET_LOT_SIZE
+	MinQty   Decimal `json:"minQty,omitempty"`
+	MaxQty   Decimal `json:"maxQty,omitempty"`
+	StepSize Decimal `json:"stepSize,omitempty"`
+
+	// MIN_NOTIONAL, NOTIONAL
+	MinNotional      Decimal `json:"minNotional,omitempty"`
+	MaxNotional      Decimal `json:"maxNotional,omitempty"`
+	Notional         Decimal `json:"notional,omitempty"`
+	ApplyToMarket    *bool   `json:"applyToMarket,omitempty"`
+	ApplyMinToMarket *bool   `json:"applyMinToMarket,omitempty"`
+	ApplyMaxToMarket *bool   `json:"applyMaxToMarket,omitempty"`
+
+	// ICEBERG_PARTS, MAX_NUM_ORDERS, MAX_NUM_ALGO_ORDERS
+	Limit            *int `json:"limit,omitempty"`
+	MaxNumOrders     *int `json:"maxNumOrders,omitempty"`
+	MaxNumAlgoOrders *int `json:"maxNumAlgoOrders,omitempty"`
+
+	// TRAILING_DELTA
+	MinTrailingAboveDelta *int `json:"minTrailingAboveDelta,omitempty"`
+	MaxTrailingAboveDelta *int `json:"maxTrailingAboveDelta,omitempty"`
+	MinTrailingBelowDelta *int `json:"minTrailingBelowDelta,omitempty"`
+	MaxTrailingBelowDelta *int `json:"maxTrailingBelowDelta,omitempty"`
+}
+
+// SymbolInfo holds the trading rules of a symbol. Spot and futures share most fields;
+// the contract and margin fields are only set by futures.
+type SymbolInfo struct {
+	Symbol              string         `json:"symbol"`
+	Status              string         `json:"status"`
+	BaseAsset           string         `json:"baseAsset"`
+	BaseAssetPrecision  int            `json:"baseAssetPrecision"`
+	QuoteAsset          string         `json:"quoteAsset"`
+	QuotePrecision      int            `json:"quotePrecision"`
+	QuoteAssetPrecision int            `json:"quoteAssetPrecision,omitempty"`
+	OrderTypes          []string       `json:"orderTypes"`
+	Filters             []SymbolFilter `json:"filters"`
+	Permissions         []string       `json:"permissions,omitempty"`
+
+	// Spot only
+	IcebergAllowed         *bool `json:"icebergAllowed,omitempty"`
+	OcoAllowed             *bool `json:"ocoAllowed,omitempty"`
+	IsSpotTradingAllowed   *bool `json:"isSpotTradingAllowed,omitempty"`
+	IsMarginTradingAllowed *bool `json:"isMarginTradingAllowed,omitempty"`
+
+	// Futures only
+	Pair                  string   `json:"pair,omitempty"`
+	ContractType          string   `json:"contractType,omitempty"`
+	DeliveryDate          int64    `json:"deliveryDate,omitempty"`
+	OnboardDate           int64    `json:"onboardDate,omitempty"`
+	MarginAsset           string   `json:"marginAsset,omitempty"`
+	MaintMarginPercent    Decimal  `json:"maintMarginPercent,omitempty"`
+	RequiredMarginPercent Decimal  `json:"requiredMarginPercent,omitempty"`
+	PricePrecision        int      `json:"pricePrecision,omitempty"`
+	QuantityPrecision     int      `json:"quantityPrecision,omitempty"`
+	UnderlyingType        string   `json:"underlyingType,omitempty"`
+	TriggerProtect        Decimal  `json:"triggerProtect,omitempty"`
+	LiquidationFee        Decimal  `json:"liquidationFee,omitempty"`
+	MarketTakeBound       Decimal  `json:"marketTakeBound,omitempty"`
+	TimeInForce           []string `json:"timeInForce,omitempty"`
+}
+
+// Filter returns the filter of the given type, or nil if the symbol has none.
+func (s *SymbolInfo) Filter(filterType string) *SymbolFilter {
+	for i := range s.Filters {
+		if s.Filters[i].FilterType == filterType {
+			return &s.Filters[i]
+		}
+	}
+	return nil
+}
+
+// ExchangeInfo holds the current exchange trading rules and symbol information.
+type ExchangeInfo struct {
+	Timezone        string         `json:"timezone"`
+	ServerTime      int64          `json:"serverTime"`
+	RateLimits      []RateLimit    `json:"rateLimits"`
+	ExchangeFilters []SymbolFilter `json:"exchangeFilters"`
+	Symbols         []SymbolInfo   `json:"symbols"`
+}
+
+// Symbol returns the rules of the given symbol, or nil if it is not listed.
+func (e *ExchangeInfo) Symbol(symbol string) *SymbolInfo {
+	for i := range e.Symbols {
+		if e.Symbols[i].Symbol == symbol {
+			return &e.Symbols[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package model
+
+// MarkPrice is the mark price and funding information of a futures symbol (premiumIndex).
+type MarkPrice struct {
+	Symbol               string  `json:"symbol"`
+	MarkPrice            Decimal `json:"markPrice"`
+	IndexPrice           Decimal `json:"indexPrice"`
+	EstimatedSettlePrice Decimal `json:"estimatedSettlePrice"`
+	LastFundingRate      Decimal `json:"lastFundingRate"`
+	InterestRate         Decimal `json:"interestRate"`
+	NextFundingTime      int64   `json:"nextFundingTime"`
+	Time                 int64   `json:"time"`
+}
+
+// FundingRate is one entry of the funding rate history.
+type FundingRate struct {
+	Symbol      string  `json:"symbol"`
+	FundingRate Decimal `json:"fundingRate"`
+	FundingTime int64   `json:"fundingTime"`
+	MarkPrice   Decimal `json:"markPrice,omitempty"`
+}
+
+// ForceOrder is a liquidation or ADL order.
+type ForceOrder struct {
+	OrderID       int64   `json:"orderId"`
+	Symbol        string  `json:"symbol"`
+	Status        string  `json:"status"`
+	ClientOrderID string  `json:"clientOrderId"`
+	Price         Decimal `json:"price"`
+	AvgPrice      Decimal `json:"avgPrice"`
+	OrigQty       Decimal `json:"origQty"`
+	ExecutedQty   Decimal `json:"executedQty"`
+	CumQuote      Decimal `json:"cumQuote"`
+	TimeInForce   string  `json:"timeInForce"`
+	Type          string  `json:"type"`
+	ReduceOnly    bool    `json:"reduceOnly"`
+	ClosePosition bool    `json:"closePosition"`
+	Side          string  `json:"side"`
+	PositionSide  string  `json:"positionSide"`
+	StopPrice     Decimal `json:"stopPrice"`
+	WorkingType   string  `json:"workingType"`
+	OrigType      string  `json:"origType"`
+	Time          int64   `json:"time"`
+	UpdateTime    int64   `json:"updateTime"`
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Kline is a single candlestick. Binance encodes it as a positional JSON array:
+// [openTime, open, high, low, close, volume, closeTime, quoteVolume, trades,
+// takerBuyBaseVolume, takerBuyQuoteVolume, ignore].
+type Kline struct {
+	OpenTime            int64
+	Open                Decimal
+	High                Decimal
+	Low                 Decimal
+	Close               Decimal
+	Volume              Decimal
+	CloseTime           int64
+	QuoteVolume         Decimal
+	TradeCount          int64
+	TakerBuyBaseVolume  Decimal
+	TakerBuyQuoteVolume Decimal
+}
+
+// UnmarshalJSON decodes the positional array form of a kline.
+func (k *Kline) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("kline: %w", err)
+	}
+	if len(raw) < 11 {
+		return fmt.Errorf("kline: expected at least 11 fields, got %d", len(raw))
+	}
+	fields := []interface{}{
+		&k.OpenTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume,
+		&k.CloseTime, &k.QuoteVolume, &k.TradeCount, &k.TakerBuyBaseVolume, &k.TakerBuyQuoteVolume,
+	}
+	for i, field := range fields {
+		if err := json.Unmarshal(raw[i], field); err != nil {
+			return fmt.Errorf("kline field %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the kline back into the Binance positional array form.
+func (k Kline) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{
+		k.OpenTime, k.Open, k.High, k.Low, k.Close, k.Volume,
+		k.CloseTime, k.QuoteVolume, k.TradeCount, k.TakerBuyBaseVolume, k.TakerBuyQuoteVolume, "0",
+	})
+}
+
+// PriceLevel is one [price, quantity] entry of an order book side.
+type PriceLevel struct {
+	Price    Decimal
+	Quantity Decimal
+}
+
+// UnmarshalJSON decodes the [price, quantity] array form.
+func (p *PriceLevel) UnmarshalJSON(data []byte) error {
+	var raw []Decimal
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("price level: %w", err)
+	}
+	if len(raw) < 2 {
+		return fmt.Errorf("price level: expected 2 fields, got %d", len(raw))
+	}
+	p.Price, p.Quantity = raw[0], raw[1]
+	return nil
+}
+
+// MarshalJSON encodes the level back into the [price, quantity] array form.
+func (p PriceLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]Decimal{p.Price, p.Quantity})
+}
+
+// DepthSnapshot is an order book snapshot. EventTime and TransactionTime are only set by futures.
+type DepthSnapshot struct {
+	LastUpdateID    int64        `json:"lastUpdateId"`
+	EventTime       int64        `json:"E,omitempty"`
+	TransactionTime int64        `json:"T,omitempty"`
+	Bids            []PriceLevel `json:"bids"`
+	Asks            []PriceLevel `json:"asks"`
+}
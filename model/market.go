@@ -0,0 +1,88 @@
+package model
+
+// Ping is the response of the ping endpoints. ServerTime is the exchange time estimated
+// from the measured clock offset, and LatencyMs the round trip of the ping.
+type Ping struct {
+	ServerTime int64   `json:"serverTime"`
+	Message    string  `json:"message"`
+	LatencyMs  float64 `json:"latencyMs"`
+}
+
+// ServerTime is the response of the time endpoints.
+type ServerTime struct {
+	ServerTime int64 `json:"serverTime"`
+}
+
+// TickerPrice is the latest price for a symbol. Time is only set by futures.
+type TickerPrice struct {
+	Symbol string  `json:"symbol"`
+	Price  Decimal `json:"price"`
+	Time   int64   `json:"time,omitempty"`
+}
+
+// BookTicker is the best bid/ask on the order book. LastUpdateID and Time are only set by futures.
+type BookTicker struct {
+	Symbol       string  `json:"symbol"`
+	BidPrice     Decimal `json:"bidPrice"`
+	BidQty       Decimal `json:"bidQty"`
+	AskPrice     Decimal `json:"askPrice"`
+	AskQty       Decimal `json:"askQty"`
+	LastUpdateID int64   `json:"lastUpdateId,omitempty"`
+	Time         int64   `json:"time,omitempty"`
+}
+
+// Ticker24h is the rolling 24 hour price change statistics of a symbol.
+// The previous close and top-of-book fields are only set by spot.
+type Ticker24h struct {
+	Symbol             string  `json:"symbol"`
+	PriceChange        Decimal `json:"priceChange"`
+	PriceChangePercent Decimal `json:"priceChangePercent"`
+	WeightedAvgPrice   Decimal `json:"weightedAvgPrice"`
+	PrevClosePrice     Decimal `json:"prevClosePrice,omitempty"`
+	LastPrice          Decimal `json:"lastPrice"`
+	LastQty            Decimal `json:"lastQty"`
+	BidPrice           Decimal `json:"bidPrice,omitempty"`
+	BidQty             Decimal `json:"bidQty,omitempty"`
+	AskPrice           Decimal `json:"askPrice,omitempty"`
+	AskQty             Decimal `json:"askQty,omitempty"`
+	OpenPrice          Decimal `json:"openPrice"`
+	HighPrice          Decimal `json:"highPrice"`
+	LowPrice           Decimal `json:"lowPrice"`
+	Volume             Decimal `json:"volume"`
+	QuoteVolume        Decimal `json:"quoteVolume"`
+	OpenTime           int64   `json:"openTime"`
+	CloseTime          int64   `json:"closeTime"`
+	FirstID            int64   `json:"firstId"`
+	LastID             int64   `json:"lastId"`
+	Count              int64   `json:"count"`
+}
+
+// Trade is a single recent or historical trade. IsBestMatch is only set by spot.
+type Trade struct {
+	ID           int64   `json:"id"`
+	Price        Decimal `json:"price"`
+	Qty          Decimal `json:"qty"`
+	QuoteQty     Decimal `json:"quoteQty"`
+	Time         int64   `json:"time"`
+	IsBuyerMaker bool    `json:"isBuyerMaker"`
+	IsBestMatch  *bool   `json:"isBestMatch,omitempty"`
+}
+
+// AggTrade is a compressed, aggregate trade.
+type AggTrade struct {
+	AggTradeID   int64   `json:"a"`
+	Price        Decimal `json:"p"`
+	Quantity     Decimal `json:"q"`
+	FirstTradeID int64   `json:"f"`
+	LastTradeID  int64   `json:"l"`
+	Timestamp    int64   `json:"T"`
+	IsBuyerMaker bool    `json:"m"`
+	IsBestMatch  *bool   `json:"M,omitempty"`
+}
+
+// AvgPrice is the current average price of a spot symbol.
+type AvgPrice struct {
+	Mins      int64   `json:"mins"`
+	Price     Decimal `json:"price"`
+	CloseTime int64   `json:"closeTime,omitempty"`
+}
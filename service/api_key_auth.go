@@ -0,0 +1,327 @@
+package service
+
+import (
+	"container/list"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// Errors returned by APIKeyAuth.Allow for requests that cannot be attributed to a client.
+var (
+	ErrMissingAPIKey = errors.New("missing API key")
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+// maxAnonymousClients bounds the number of client IPs tracked for anonymous requests.
+// Once reached, the least recently seen client is forgotten if it is idle, and new
+// clients are refused otherwise.
+const maxAnonymousClients = 100000
+
+// anonymousSweepInterval is how often the state of idle anonymous clients is dropped.
+const anonymousSweepInterval = time.Minute
+
+// APIKeyAuth identifies the client behind every request, by API key or, for anonymous
+// requests, by IP, and limits its requests with the token bucket and daily quota of its
+// tier.
+type APIKeyAuth interface {
+	// Allow identifies the client holding key, or the anonymous client at clientIP when
+	// key is empty, and takes one request from its limits. It fails with
+	// ErrMissingAPIKey or ErrInvalidAPIKey if the client cannot be identified.
+	Allow(key, clientIP string) (ClientDecision, error)
+	// Usage reports the requests made with every key and anonymously.
+	Usage() APIKeyUsageReport
+}
+
+// ClientDecision is the outcome of one request against the limits of its client.
+type ClientDecision struct {
+	// Client is the name of the key, or empty for anonymous requests.
+	Client  string
+	Tier    string
+	Allowed bool
+	// QuotaExceeded tells a refusal by the daily quota from one by the token bucket.
+	QuotaExceeded bool
+	// Limit is the bucket size and Remaining the requests left in it; it is full again
+	// at Reset.
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	// QuotaLimit is the daily quota, zero if unlimited, and QuotaRemaining the requests
+	// left today; the quota starts over at QuotaReset.
+	QuotaLimit     int
+	QuotaRemaining int
+	QuotaReset     time.Time
+	// RetryAfter is how long a refused client should wait.
+	RetryAfter time.Duration
+}
+
+// APIKeyUsageReport is the usage of every key, by key name, and of anonymous clients.
+type APIKeyUsageReport struct {
+	Keys      map[string]APIKeyUsage `json:"keys"`
+	Anonymous *APIKeyUsage           `json:"anonymous,omitempty"`
+}
+
+// APIKeyUsage reports the requests of one key, or of all anonymous clients together.
+type APIKeyUsage struct {
+	Tier string `json:"tier"`
+	// Requests and Rejected count the requests let through and refused since startup.
+	Requests uint64 `json:"requests"`
+	Rejected uint64 `json:"rejected"`
+	// RequestsToday is counted against DailyQuota, zero if unlimited. Anonymous clients
+	// each have their own quota.
+	RequestsToday int        `json:"requestsToday"`
+	DailyQuota    int        `json:"dailyQuota"`
+	LastUsed      *time.Time `json:"lastUsed,omitempty"`
+	// Clients is the number of client IPs tracked for anonymous requests.
+	Clients int `json:"clients,omitempty"`
+}
+
+// clientLimiter holds the token bucket and daily count of one client.
+type clientLimiter struct {
+	tierName string
+	tier     config.RateTier
+	tokens   float64
+	updated  time.Time
+	day      time.Time
+	today    int
+	lastUsed time.Time
+}
+
+func newClientLimiter(tierName string, tier config.RateTier, now time.Time) *clientLimiter {
+	return &clientLimiter{tierName: tierName, tier: tier, tokens: float64(tier.Burst), updated: now, day: utcDay(now)}
+}
+
+// allow refills the bucket, resets the daily count on a new day and takes one request.
+func (l *clientLimiter) allow(now time.Time) ClientDecision {
+	l.refill(now)
+	l.lastUsed = now
+	decision := ClientDecision{Tier: l.tierName, Limit: l.tier.Burst, QuotaLimit: l.tier.DailyQuota, QuotaReset: l.day.AddDate(0, 0, 1)}
+	switch {
+	case l.tier.DailyQuota > 0 && l.today >= l.tier.DailyQuota:
+		decision.QuotaExceeded = true
+		decision.RetryAfter = decision.QuotaReset.Sub(now)
+	case l.tokens < 1:
+		decision.RetryAfter = time.Duration((1 - l.tokens) / l.tier.RatePerSecond * float64(time.Second))
+	default:
+		decision.Allowed = true
+		l.tokens--
+		l.today++
+	}
+	decision.Remaining = int(l.tokens)
+	decision.Reset = now.Add(time.Duration((float64(l.tier.Burst) - l.tokens) / l.tier.RatePerSecond * float64(time.Second)))
+	if l.tier.DailyQuota > 0 {
+		decision.QuotaRemaining = l.tier.DailyQuota - l.today
+	}
+	return decision
+}
+
+func (l *clientLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.updated); elapsed > 0 {
+		l.tokens = math.Min(float64(l.tier.Burst), l.tokens+elapsed.Seconds()*l.tier.RatePerSecond)
+		l.updated = now
+	}
+	if day := utcDay(now); day.After(l.day) {
+		l.day = day
+		l.today = 0
+	}
+}
+
+// idle reports whether dropping the limiter would not change any decision: its bucket
+// is full and it has not been used today.
+func (l *clientLimiter) idle(now time.Time) bool {
+	return l.idleAfter(now) <= 0
+}
+
+// idleAfter returns how long it takes the limiter to become idle.
+func (l *clientLimiter) idleAfter(now time.Time) time.Duration {
+	l.refill(now)
+	if l.tier.DailyQuota > 0 && l.today > 0 {
+		return l.day.AddDate(0, 0, 1).Sub(now)
+	}
+	return time.Duration((float64(l.tier.Burst) - l.tokens) / l.tier.RatePerSecond * float64(time.Second))
+}
+
+// anonymousClient is the state of one client IP, in the LRU list of anonymous clients.
+type anonymousClient struct {
+	ip      string
+	limiter *clientLimiter
+}
+
+// keyClient is the state of one API key.
+type keyClient struct {
+	name     string
+	limiter  *clientLimiter
+	requests uint64
+	rejected uint64
+}
+
+type apiKeyAuth struct {
+	mu   sync.Mutex
+	keys map[string]*keyClient
+	// anonymousTier is empty when anonymous requests are refused.
+	anonymousTier string
+	anonymousRate config.RateTier
+	// clients indexes the anonymous clients in lru, most recently seen first.
+	clients      map[string]*list.Element
+	lru          *list.List
+	maxClients   int
+	anonRequests uint64
+	anonRejected uint64
+	anonLastUsed time.Time
+	lastSweep    time.Time
+}
+
+// NewAPIKeyAuth creates and returns a new APIKeyAuth for the keys and tiers of cfg.
+func NewAPIKeyAuth(cfg config.AuthConfig) APIKeyAuth {
+	now := time.Now()
+	a := &apiKeyAuth{
+		keys:       make(map[string]*keyClient, len(cfg.Keys)),
+		clients:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxClients: maxAnonymousClients,
+		lastSweep:  now,
+	}
+	for _, key := range cfg.Keys {
+		a.keys[key.Key] = &keyClient{name: key.ClientName(), limiter: newClientLimiter(key.Tier, cfg.Tiers[key.Tier], now)}
+	}
+	if tier, ok := cfg.Tiers[cfg.AnonymousTier]; ok && cfg.AnonymousTier != "" {
+		a.anonymousTier = cfg.AnonymousTier
+		a.anonymousRate = tier
+	}
+	return a
+}
+
+func (a *apiKeyAuth) Allow(key, clientIP string) (ClientDecision, error) {
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if key != "" {
+		client, ok := a.keys[key]
+		if !ok {
+			return ClientDecision{}, ErrInvalidAPIKey
+		}
+		decision := client.limiter.allow(now)
+		decision.Client = client.name
+		if decision.Allowed {
+			client.requests++
+		} else {
+			client.rejected++
+		}
+		return decision, nil
+	}
+
+	if a.anonymousTier == "" {
+		return ClientDecision{}, ErrMissingAPIKey
+	}
+	a.sweep(now)
+	elem, ok := a.clients[clientIP]
+	if ok {
+		a.lru.MoveToFront(elem)
+	} else {
+		if len(a.clients) >= a.maxClients && !a.evictIdle(now) {
+			// Every tracked client is active, and forgetting one would reset its limits.
+			retryAfter := a.lru.Back().Value.(*anonymousClient).limiter.idleAfter(now)
+			a.anonRejected++
+			return ClientDecision{
+				Tier:       a.anonymousTier,
+				Limit:      a.anonymousRate.Burst,
+				Reset:      now.Add(retryAfter),
+				QuotaLimit: a.anonymousRate.DailyQuota,
+				QuotaReset: utcDay(now).AddDate(0, 0, 1),
+				RetryAfter: retryAfter,
+			}, nil
+		}
+		elem = a.lru.PushFront(&anonymousClient{ip: clientIP, limiter: newClientLimiter(a.anonymousTier, a.anonymousRate, now)})
+		a.clients[clientIP] = elem
+	}
+	decision := elem.Value.(*anonymousClient).limiter.allow(now)
+	a.anonLastUsed = now
+	if decision.Allowed {
+		a.anonRequests++
+	} else {
+		a.anonRejected++
+	}
+	return decision, nil
+}
+
+// sweep drops the idle anonymous clients, at most once per anonymousSweepInterval. The
+// caller must hold a.mu.
+func (a *apiKeyAuth) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < anonymousSweepInterval {
+		return
+	}
+	a.lastSweep = now
+	for elem := a.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*anonymousClient).limiter.idle(now) {
+			a.removeClient(elem)
+		}
+		elem = prev
+	}
+}
+
+// evictIdle forgets the least recently seen anonymous client if it is idle, and reports
+// whether it did. The caller must hold a.mu.
+func (a *apiKeyAuth) evictIdle(now time.Time) bool {
+	back := a.lru.Back()
+	if back == nil || !back.Value.(*anonymousClient).limiter.idle(now) {
+		return false
+	}
+	a.removeClient(back)
+	return true
+}
+
+func (a *apiKeyAuth) removeClient(elem *list.Element) {
+	a.lru.Remove(elem)
+	delete(a.clients, elem.Value.(*anonymousClient).ip)
+}
+
+func (a *apiKeyAuth) Usage() APIKeyUsageReport {
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	report := APIKeyUsageReport{Keys: make(map[string]APIKeyUsage, len(a.keys))}
+	for _, client := range a.keys {
+		client.limiter.refill(now)
+		usage := APIKeyUsage{
+			Tier:          client.limiter.tierName,
+			Requests:      client.requests,
+			Rejected:      client.rejected,
+			RequestsToday: client.limiter.today,
+			DailyQuota:    client.limiter.tier.DailyQuota,
+		}
+		if !client.limiter.lastUsed.IsZero() {
+			lastUsed := client.limiter.lastUsed
+			usage.LastUsed = &lastUsed
+		}
+		report.Keys[client.name] = usage
+	}
+	if a.anonymousTier != "" {
+		usage := APIKeyUsage{
+			Tier:       a.anonymousTier,
+			Requests:   a.anonRequests,
+			Rejected:   a.anonRejected,
+			DailyQuota: a.anonymousRate.DailyQuota,
+			Clients:    len(a.clients),
+		}
+		for elem := a.lru.Front(); elem != nil; elem = elem.Next() {
+			limiter := elem.Value.(*anonymousClient).limiter
+			limiter.refill(now)
+			usage.RequestsToday += limiter.today
+		}
+		if !a.anonLastUsed.IsZero() {
+			lastUsed := a.anonLastUsed
+			usage.LastUsed = &lastUsed
+		}
+		report.Anonymous = &usage
+	}
+	return report
+}
+
+// utcDay returns the start of the UTC day of t.
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+func TestAnonymousClientsBeyondTheLimit(t *testing.T) {
+	cfg := config.AuthConfig{
+		Tiers:         map[string]config.RateTier{"anonymous": {RatePerSecond: 1000, Burst: 1}},
+		AnonymousTier: "anonymous",
+	}
+	auth := NewAPIKeyAuth(cfg).(*apiKeyAuth)
+	auth.maxClients = 2
+
+	allow := func(ip string) ClientDecision {
+		t.Helper()
+		decision, err := auth.Allow("", ip)
+		if err != nil {
+			t.Fatalf("Allow(%s): %v", ip, err)
+		}
+		return decision
+	}
+	allow("10.0.0.1")
+	allow("10.0.0.2")
+
+	// Both clients have just emptied their buckets, so neither can be forgotten.
+	if decision := allow("10.0.0.3"); decision.Allowed || decision.RetryAfter <= 0 {
+		t.Fatalf("new client with every tracked client active = %+v, want a refusal with a retry delay", decision)
+	}
+
+	// Once both are idle, the least recently seen one makes room for the new client.
+	time.Sleep(5 * time.Millisecond)
+	allow("10.0.0.1")
+	time.Sleep(5 * time.Millisecond)
+	if decision := allow("10.0.0.3"); !decision.Allowed {
+		t.Fatalf("new client with an idle tracked client = %+v, want it allowed", decision)
+	}
+	if _, ok := auth.clients["10.0.0.2"]; ok {
+		t.Fatal("the least recently seen client was kept")
+	}
+	if _, ok := auth.clients["10.0.0.1"]; !ok {
+		t.Fatal("a recently seen client was forgotten")
+	}
+	if usage := auth.Usage().Anonymous; usage.Clients != 2 || usage.Rejected != 1 {
+		t.Fatalf("anonymous usage = %+v, want 2 clients and 1 rejected request", usage)
+	}
+}
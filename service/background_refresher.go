@@ -0,0 +1,90 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// BackgroundRefresher runs cache refreshes in the background, at most one per key and
+// at most a fixed number at a time, so a slow key cannot hold up the others.
+type BackgroundRefresher interface {
+	// Trigger starts fn for key unless a refresh of key is already pending or every
+	// slot is busy. It reports whether fn was started.
+	Trigger(key string, fn func()) bool
+	Stats() RefreshStats
+}
+
+// RefreshStats reports the outcome of background refresh requests.
+type RefreshStats struct {
+	// Started is the number of refreshes that were run.
+	Started uint64 `json:"started"`
+	// SkippedPending is the number of requests dropped because the key was already refreshing.
+	SkippedPending uint64 `json:"skippedPending"`
+	// SkippedBusy is the number of requests dropped because every slot was in use.
+	SkippedBusy uint64 `json:"skippedBusy"`
+	// Running is the number of refreshes currently in progress.
+	Running int `json:"running"`
+	// MaxConcurrent is the number of refreshes allowed to run at once.
+	MaxConcurrent int `json:"maxConcurrent"`
+}
+
+type backgroundRefresher struct {
+	mu             sync.Mutex
+	pending        map[string]struct{}
+	slots          chan struct{}
+	started        atomic.Uint64
+	skippedPending atomic.Uint64
+	skippedBusy    atomic.Uint64
+}
+
+// NewBackgroundRefresher creates and returns a new BackgroundRefresher instance that
+// runs at most maxConcurrent refreshes at a time.
+func NewBackgroundRefresher(maxConcurrent int) BackgroundRefresher {
+	return &backgroundRefresher{
+		pending: make(map[string]struct{}),
+		slots:   make(chan struct{}, maxConcurrent),
+	}
+}
+
+// Trigger starts fn in a new goroutine only after the key and a slot have been reserved,
+// so no goroutine is spawned for a refresh that would be dropped anyway.
+func (r *backgroundRefresher) Trigger(key string, fn func()) bool {
+	r.mu.Lock()
+	if _, ok := r.pending[key]; ok {
+		r.mu.Unlock()
+		r.skippedPending.Add(1)
+		return false
+	}
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		r.mu.Unlock()
+		r.skippedBusy.Add(1)
+		return false
+	}
+	r.pending[key] = struct{}{}
+	r.mu.Unlock()
+
+	r.started.Add(1)
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.pending, key)
+			r.mu.Unlock()
+			<-r.slots
+		}()
+		fn()
+	}()
+	return true
+}
+
+// Stats returns a snapshot of the refresher counters.
+func (r *backgroundRefresher) Stats() RefreshStats {
+	return RefreshStats{
+		Started:        r.started.Load(),
+		SkippedPending: r.skippedPending.Load(),
+		SkippedBusy:    r.skippedBusy.Load(),
+		Running:        len(r.slots),
+		MaxConcurrent:  cap(r.slots),
+	}
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownCacheKey is returned by CacheAdmin.Refresh for a key this server has not
+// served recently, whose upstream request it therefore cannot rebuild.
+var ErrUnknownCacheKey = errors.New("unknown cache key")
+
+// delayKeySuffix marks the keys that throttle background refreshes of a cache key.
+const delayKeySuffix = ":delay"
+
+// CacheAdmin lists, invalidates and refreshes cache entries and keeps per cache name
+// statistics. Keys have the form "<upstream>_<cache name>:<suffix>", e.g.
+// "spot_klines:BTCUSDT-1m-500", and the first dash-separated part of the suffix is the
+// symbol, if any.
+type CacheAdmin interface {
+	// Keys returns the keys starting with prefix, sorted, with their expire times.
+	Keys(prefix string) []CacheKeyInfo
+	// Invalidate removes key and returns the number of removed entries.
+	Invalidate(key string) int
+	// InvalidatePrefix removes every key starting with prefix.
+	InvalidatePrefix(prefix string) int
+	// InvalidateSymbol removes every key of symbol across all cache names, whatever the
+	// case of the symbol in the key.
+	InvalidateSymbol(symbol string) int
+	// Refresh fetches key from the upstream and stores the result.
+	Refresh(ctx context.Context, key string) error
+	// Stats returns lookup statistics by "<upstream>_<cache name>".
+	Stats() map[string]CacheNameStats
+
+	// track remembers how to refresh key. Only recently used keys are kept.
+	track(key string, refresh func(ctx context.Context) error)
+	// record counts the outcome of a lookup for cacheName.
+	record(cacheName, status string)
+}
+
+// CacheKeyInfo describes one cache key.
+type CacheKeyInfo struct {
+	Key string `json:"key"`
+	// ExpireTime is unset when the entry has expired and is only kept for stale serving.
+	ExpireTime *time.Time `json:"expireTime"`
+	// Refreshable reports whether Refresh can be used for the key.
+	Refreshable bool `json:"refreshable"`
+}
+
+// CacheNameStats reports the lookups of one cache name.
+type CacheNameStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Stale  uint64 `json:"stale"`
+	Errors uint64 `json:"errors"`
+	// HitRatio is the share of lookups served from the cache, stale values included.
+	HitRatio float64 `json:"hitRatio"`
+}
+
+// cacheErrorStatus is recorded for lookups that failed without a cached value.
+const cacheErrorStatus = "ERROR"
+
+type trackedKey struct {
+	key     string
+	refresh func(ctx context.Context) error
+}
+
+type cacheAdmin struct {
+	cache   CacheBackend
+	maxKeys int
+
+	mu      sync.Mutex
+	tracked map[string]*list.Element
+	lru     *list.List
+	stats   map[string]*CacheNameStats
+}
+
+// NewCacheAdmin creates and returns a new CacheAdmin over cache that remembers how to
+// refresh up to maxKeys recently used keys.
+func NewCacheAdmin(cache CacheBackend, maxKeys int) CacheAdmin {
+	return &cacheAdmin{
+		cache:   cache,
+		maxKeys: maxKeys,
+		tracked: make(map[string]*list.Element),
+		lru:     list.New(),
+		stats:   make(map[string]*CacheNameStats),
+	}
+}
+
+func (a *cacheAdmin) Keys(prefix string) []CacheKeyInfo {
+	keys := a.cache.Keys(prefix)
+	sort.Strings(keys)
+	infos := make([]CacheKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		if strings.HasSuffix(key, delayKeySuffix) {
+			continue
+		}
+		info := CacheKeyInfo{Key: key, Refreshable: a.refresher(key) != nil}
+		if expireTime, ok := a.cache.GetExpireTime(key); ok {
+			info.ExpireTime = expireTime
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (a *cacheAdmin) Invalidate(key string) int {
+	return a.invalidateMatching(key, func(k string) bool { return k == key })
+}
+
+func (a *cacheAdmin) InvalidatePrefix(prefix string) int {
+	return a.invalidateMatching(prefix, func(string) bool { return true })
+}
+
+func (a *cacheAdmin) InvalidateSymbol(symbol string) int {
+	if symbol == "" {
+		return 0
+	}
+	// Keys keep the symbol as the client sent it, in any case.
+	return a.invalidateMatching("", func(key string) bool { return strings.EqualFold(keySymbol(key), symbol) })
+}
+
+// invalidateMatching removes the keys starting with prefix that match, together with
+// their delay keys.
+func (a *cacheAdmin) invalidateMatching(prefix string, match func(key string) bool) int {
+	removed := 0
+	for _, key := range a.cache.Keys(prefix) {
+		if !match(strings.TrimSuffix(key, delayKeySuffix)) {
+			continue
+		}
+		a.cache.Del(key)
+		if !strings.HasSuffix(key, delayKeySuffix) {
+			removed++
+		}
+	}
+	return removed
+}
+
+func (a *cacheAdmin) Refresh(ctx context.Context, key string) error {
+	refresh := a.refresher(key)
+	if refresh == nil {
+		return ErrUnknownCacheKey
+	}
+	return refresh(ctx)
+}
+
+func (a *cacheAdmin) Stats() map[string]CacheNameStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := make(map[string]CacheNameStats, len(a.stats))
+	for name, s := range a.stats {
+		snapshot := *s
+		if lookups := s.Hits + s.Misses + s.Stale + s.Errors; lookups > 0 {
+			snapshot.HitRatio = float64(s.Hits+s.Stale) / float64(lookups)
+		}
+		stats[name] = snapshot
+	}
+	return stats
+}
+
+func (a *cacheAdmin) track(key string, refresh func(ctx context.Context) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if elem, ok := a.tracked[key]; ok {
+		a.lru.MoveToFront(elem)
+		return
+	}
+	a.tracked[key] = a.lru.PushFront(&trackedKey{key: key, refresh: refresh})
+	for a.lru.Len() > a.maxKeys {
+		oldest := a.lru.Remove(a.lru.Back()).(*trackedKey)
+		delete(a.tracked, oldest.key)
+	}
+}
+
+func (a *cacheAdmin) record(cacheName, status string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.stats[cacheName]
+	if !ok {
+		s = &CacheNameStats{}
+		a.stats[cacheName] = s
+	}
+	switch status {
+	case CacheHit:
+		s.Hits++
+	case CacheMiss:
+		s.Misses++
+	case CacheStale:
+		s.Stale++
+	case cacheErrorStatus:
+		s.Errors++
+	}
+}
+
+func (a *cacheAdmin) refresher(key string) func(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if elem, ok := a.tracked[key]; ok {
+		return elem.Value.(*trackedKey).refresh
+	}
+	return nil
+}
+
+// cacheNameOf returns the "<upstream>_<cache name>" part of a cache key.
+func cacheNameOf(key string) string {
+	name, _, _ := strings.Cut(key, ":")
+	return name
+}
+
+// keySymbol returns the symbol part of a cache key, or "" for keys without a symbol
+// such as "spot_exchangeinfo:global".
+func keySymbol(key string) string {
+	_, suffix, ok := strings.Cut(key, ":")
+	if !ok {
+		return ""
+	}
+	symbol, _, _ := strings.Cut(suffix, "-")
+	if symbol == "global" {
+		return ""
+	}
+	return symbol
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+func TestInvalidateSymbolIgnoresCase(t *testing.T) {
+	cfg := config.Default().Cache
+	cache := newLocalCacheService(cfg)
+	admin := NewCacheAdmin(cache, cfg.MaxEntries)
+	for _, key := range []string{
+		"spot_depth:btcusdt-10",
+		"spot_depth:btcusdt-10" + delayKeySuffix,
+		"futures_tickerprice:BTCUSDT",
+		"spot_klines:BtcUsdt-1m-100",
+		"spot_depth:ETHUSDT-10",
+		"spot_exchangeinfo:global",
+	} {
+		cache.Set(key, newRawResponse([]byte(`{}`)), time.Minute)
+	}
+
+	if removed := admin.InvalidateSymbol("BTCUSDT"); removed != 3 {
+		t.Fatalf("removed = %d, want 3", removed)
+	}
+	keys := cache.Keys("")
+	slices.Sort(keys)
+	if want := []string{"spot_depth:ETHUSDT-10", "spot_exchangeinfo:global"}; !slices.Equal(keys, want) {
+		t.Fatalf("keys left = %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache backend names accepted in config.CacheConfig.Backend.
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+	CacheBackendTiered = "tiered"
+)
+
+// CacheBackend stores cached upstream responses as RawResponse values. The in-memory
+// implementation keeps them as they are; shared implementations store their bodies and
+// return new RawResponse values, which are decoded on first use by the caller that
+// knows their type.
+type CacheBackend interface {
+	Set(key string, value interface{}, ttl time.Duration)
+	Get(key string) (interface{}, bool)
+	// GetEntry returns the entry for key even if it has expired, as long as it is still
+	// within the stale grace window. Callers check CacheEntry.Expired themselves.
+	GetEntry(key string) (*CacheEntry, bool)
+	GetExpireTime(key string) (*time.Time, bool)
+	Del(key string)
+	Has(key string) bool
+	// Keys returns the keys that start with prefix, in no particular order, including
+	// expired entries still held for stale serving.
+	Keys(prefix string) []string
+	Stats() CacheStats
+}
+
+// CacheEntry is a cached value together with when it was stored and when it expires.
+type CacheEntry struct {
+	Value      interface{}
+	StoredAt   time.Time
+	ExpireTime time.Time
+}
+
+// Expired reports whether the entry is past its expire time.
+func (e *CacheEntry) Expired() bool {
+	return time.Now().After(e.ExpireTime)
+}
+
+// CacheStats reports the size and effectiveness of a cache backend. Entries, Bytes and
+// the eviction counters are only tracked by the in-memory backend.
+type CacheStats struct {
+	// Backend is the backend name, e.g. "memory" or "redis".
+	Backend string `json:"backend"`
+	// Entries is the number of entries currently held, including expired ones kept for
+	// stale serving.
+	Entries int `json:"entries"`
+	// Bytes is the approximate size of the held entries.
+	Bytes int64 `json:"bytes"`
+	// MaxEntries and MaxBytes are the configured capacity.
+	MaxEntries int   `json:"maxEntries"`
+	MaxBytes   int64 `json:"maxBytes"`
+	// Hits is the number of lookups that found a fresh entry.
+	Hits uint64 `json:"hits"`
+	// Misses is the number of lookups that found no entry or an expired one.
+	Misses uint64 `json:"misses"`
+	// Evictions is the number of entries dropped to stay within capacity.
+	Evictions uint64 `json:"evictions"`
+	// EvictionsByName breaks Evictions down by "<upstream>_<cache name>". Delay keys
+	// are not included.
+	EvictionsByName map[string]uint64 `json:"evictionsByName,omitempty"`
+	// Expirations is the number of entries dropped after their stale grace window.
+	Expirations uint64 `json:"expirations"`
+	// Errors is the number of failed calls to a shared backend.
+	Errors uint64 `json:"errors"`
+	// Tiers holds the stats of each tier of a tiered backend, L1 first.
+	Tiers []CacheStats `json:"tiers,omitempty"`
+}
+
+// NewCacheBackend creates the cache backend selected by cfg.Backend.
+func NewCacheBackend(cfg config.CacheConfig) (CacheBackend, error) {
+	switch cfg.Backend {
+	case CacheBackendMemory, "":
+		return NewLocalCacheService(cfg), nil
+	case CacheBackendRedis:
+		return NewRedisCacheService(newRedisClient(cfg.Redis), cfg), nil
+	case CacheBackendTiered:
+		return NewTieredCacheService(cfg, NewRedisCacheService(newRedisClient(cfg.Redis), cfg)), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.Backend)
+	}
+}
+
+func newRedisClient(cfg config.RedisConfig) redis.UniversalClient {
+	client := redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.Timeout.Duration(),
+		ReadTimeout:  cfg.Timeout.Duration(),
+		WriteTimeout: cfg.Timeout.Duration(),
+	})
+	// Redis being down at startup is not fatal: every call falls back to a miss.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout.Duration())
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		slog.Warn("Redis cache is not reachable", "addr", cfg.Addr, "error", err)
+	}
+	return client
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// cachePolicy is the resolved cache behaviour of one cache name.
+type cachePolicy struct {
+	ttl                  time.Duration
+	refreshInterval      time.Duration
+	staleWhileRevalidate bool
+}
+
+// cachePolicies resolves the cache policy of each cache name, falling back to the
+// upstream-wide TTL and delay for names without an override.
+type cachePolicies struct {
+	fallback cachePolicy
+	byName   map[string]cachePolicy
+}
+
+func newCachePolicies(cfg config.UpstreamConfig) cachePolicies {
+	p := cachePolicies{
+		fallback: cachePolicy{
+			ttl:                  cfg.CacheTTL.Duration(),
+			refreshInterval:      cfg.CacheDelay.Duration(),
+			staleWhileRevalidate: true,
+		},
+		byName: make(map[string]cachePolicy, len(cfg.CachePolicies)),
+	}
+	for name, override := range cfg.CachePolicies {
+		policy := p.fallback
+		if override.TTL > 0 {
+			policy.ttl = override.TTL.Duration()
+		}
+		if override.RefreshInterval > 0 {
+			policy.refreshInterval = override.RefreshInterval.Duration()
+		}
+		if override.StaleWhileRevalidate != nil {
+			policy.staleWhileRevalidate = *override.StaleWhileRevalidate
+		}
+		p.byName[name] = policy
+	}
+	return p
+}
+
+// get returns the policy for cacheName.
+func (p cachePolicies) get(cacheName string) cachePolicy {
+	if policy, ok := p.byName[cacheName]; ok {
+		return policy
+	}
+	return p.fallback
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache statuses reported in the X-Cache response header.
+const (
+	CacheHit   = "HIT"
+	CacheMiss  = "MISS"
+	CacheStale = "STALE"
+)
+
+// CacheResult records how the cached lookup of a request was served.
+type CacheResult struct {
+	mu         sync.Mutex
+	status     string
+	storedAt   time.Time
+	expireTime time.Time
+	etag       string
+}
+
+type cacheResultKey struct{}
+
+// WithCacheResult returns a context that collects the CacheResult of the lookups made with it.
+func WithCacheResult(ctx context.Context) (context.Context, *CacheResult) {
+	result := &CacheResult{}
+	return context.WithValue(ctx, cacheResultKey{}, result), result
+}
+
+// CacheResultFromContext returns the CacheResult installed by WithCacheResult, if any.
+func CacheResultFromContext(ctx context.Context) (*CacheResult, bool) {
+	result, ok := ctx.Value(cacheResultKey{}).(*CacheResult)
+	return result, ok
+}
+
+type privateResponseKey struct{}
+
+// WithPrivateResponse returns a context marking the response to the request as private
+// to its client, e.g. because it is metered per API key, so shared caches must not
+// store it.
+func WithPrivateResponse(ctx context.Context) context.Context {
+	return context.WithValue(ctx, privateResponseKey{}, true)
+}
+
+// IsPrivateResponse reports whether ctx was marked by WithPrivateResponse.
+func IsPrivateResponse(ctx context.Context) bool {
+	private, _ := ctx.Value(privateResponseKey{}).(bool)
+	return private
+}
+
+// Status returns the cache status and the age of the served value. The status is
+// empty when no cached lookup was made.
+func (r *CacheResult) Status() (string, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == "" {
+		return "", 0
+	}
+	return r.status, time.Since(r.storedAt)
+}
+
+// Validator returns the entity tag of the served value and when it expires. The tag is
+// empty when no cached lookup was made or the value has no tag.
+func (r *CacheResult) Validator() (string, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.etag, r.expireTime
+}
+
+// recordCacheResult stores the outcome of a lookup on the request context, if it carries a CacheResult.
+func recordCacheResult(ctx context.Context, status string, entry *CacheEntry) {
+	result, ok := CacheResultFromContext(ctx)
+	if !ok {
+		return
+	}
+	result.mu.Lock()
+	defer result.mu.Unlock()
+	result.status = status
+	result.storedAt = entry.StoredAt
+	result.expireTime = entry.ExpireTime
+	result.etag = ""
+	if raw, ok := entry.Value.(*RawResponse); ok {
+		result.etag = raw.ETag()
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"reflect"
+)
+
+// entryOverhead approximates the bookkeeping cost of one cache entry: the key's map
+// slot, the list element and the entry struct.
+const entryOverhead = 128
+
+// approximateSize estimates the memory held by a cached value in bytes. It walks the
+// value and counts headers, string and slice backing arrays, and what pointers and
+// interfaces point to. Shared pointees are counted once.
+func approximateSize(value interface{}) int64 {
+	if value == nil {
+		return 0
+	}
+	seen := make(map[uintptr]struct{})
+	return sizeOf(reflect.ValueOf(value), seen)
+}
+
+func sizeOf(v reflect.Value, seen map[uintptr]struct{}) int64 {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return int64(v.Type().Size())
+		}
+		if _, ok := seen[v.Pointer()]; ok {
+			return int64(v.Type().Size())
+		}
+		seen[v.Pointer()] = struct{}{}
+		return int64(v.Type().Size()) + sizeOf(v.Elem(), seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			return int64(v.Type().Size())
+		}
+		return int64(v.Type().Size()) + sizeOf(v.Elem(), seen)
+	case reflect.String:
+		return int64(v.Type().Size()) + int64(v.Len())
+	case reflect.Slice:
+		size := int64(v.Type().Size())
+		elem := v.Type().Elem()
+		if isFlat(elem) {
+			return size + int64(v.Cap())*int64(elem.Size())
+		}
+		size += int64(v.Cap()-v.Len()) * int64(elem.Size())
+		for i := 0; i < v.Len(); i++ {
+			size += sizeOf(v.Index(i), seen)
+		}
+		return size
+	case reflect.Array:
+		if isFlat(v.Type().Elem()) {
+			return int64(v.Type().Size())
+		}
+		var size int64
+		for i := 0; i < v.Len(); i++ {
+			size += sizeOf(v.Index(i), seen)
+		}
+		return size
+	case reflect.Struct:
+		size := int64(v.Type().Size())
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); !isFlat(field.Type()) {
+				size += sizeOf(field, seen) - int64(field.Type().Size())
+			}
+		}
+		return size
+	case reflect.Map:
+		size := int64(v.Type().Size())
+		iter := v.MapRange()
+		for iter.Next() {
+			size += sizeOf(iter.Key(), seen) + sizeOf(iter.Value(), seen)
+		}
+		return size
+	default:
+		return int64(v.Type().Size())
+	}
+}
+
+// isFlat reports whether values of t hold no references, so that their size is t.Size().
+func isFlat(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.String, reflect.Slice, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return false
+	case reflect.Array:
+		return isFlat(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !isFlat(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// SnapshotCache is implemented by cache backends whose entries live in this process and
+// can be written to and restored from a snapshot.
+type SnapshotCache interface {
+	// WriteSnapshot writes the entries that can still be served and returns their number.
+	WriteSnapshot(w io.Writer) (int, error)
+	// ReadSnapshot restores the entries of a snapshot that can still be served and
+	// returns their number. Values are restored as raw responses and decoded on first use.
+	ReadSnapshot(r io.Reader) (int, error)
+}
+
+// CacheSnapshotter saves the in-memory cache to a file and restores it on startup.
+type CacheSnapshotter interface {
+	// Restore loads the snapshot file, if there is one.
+	Restore() error
+	// Save writes the snapshot file.
+	Save() error
+	// Run saves a snapshot at the configured interval until ctx is done.
+	Run(ctx context.Context)
+}
+
+// snapshotRecord is one entry of a snapshot. Records are written as JSON lines.
+type snapshotRecord struct {
+	Key        string          `json:"key"`
+	StoredAt   time.Time       `json:"storedAt"`
+	ExpireTime time.Time       `json:"expireTime"`
+	Value      json.RawMessage `json:"value"`
+}
+
+type cacheSnapshotter struct {
+	cache    SnapshotCache
+	path     string
+	interval time.Duration
+}
+
+// noopSnapshotter is used when snapshots are disabled or the backend keeps no entries
+// in process.
+type noopSnapshotter struct{}
+
+func (noopSnapshotter) Restore() error          { return nil }
+func (noopSnapshotter) Save() error             { return nil }
+func (noopSnapshotter) Run(ctx context.Context) {}
+
+// NewCacheSnapshotter creates and returns a new CacheSnapshotter for cache.
+func NewCacheSnapshotter(cache CacheBackend, cfg config.SnapshotConfig) CacheSnapshotter {
+	if cfg.Path == "" {
+		return noopSnapshotter{}
+	}
+	snapshotCache, ok := cache.(SnapshotCache)
+	if !ok {
+		slog.Warn("Cache backend does not support snapshots, ignoring snapshot path", "backend", cache.Stats().Backend, "path", cfg.Path)
+		return noopSnapshotter{}
+	}
+	return &cacheSnapshotter{
+		cache:    snapshotCache,
+		path:     cfg.Path,
+		interval: cfg.Interval.Duration(),
+	}
+}
+
+func (s *cacheSnapshotter) Restore() error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening cache snapshot %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("error reading cache snapshot %s: %w", s.path, err)
+	}
+	defer reader.Close()
+	restored, err := s.cache.ReadSnapshot(reader)
+	if err != nil {
+		return fmt.Errorf("error reading cache snapshot %s after %d entries: %w", s.path, restored, err)
+	}
+	slog.Info("Restored cache snapshot", "entries", restored, "path", s.path)
+	return nil
+}
+
+// Save writes the snapshot to a temporary file first and renames it over the previous
+// one, so a crash while saving never leaves a truncated snapshot behind.
+func (s *cacheSnapshotter) Save() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating cache snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	writer := gzip.NewWriter(tmp)
+	saved, err := s.cache.WriteSnapshot(writer)
+	if err != nil {
+		return fmt.Errorf("error writing cache snapshot: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error writing cache snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing cache snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("error replacing cache snapshot %s: %w", s.path, err)
+	}
+	slog.Info("Saved cache snapshot", "entries", saved, "path", s.path)
+	return nil
+}
+
+func (s *cacheSnapshotter) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				slog.ErrorContext(ctx, "Failed to save cache snapshot", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WriteSnapshot also writes the delay keys, so restored entries are not all refreshed
+// on their first read.
+func (c *localCacheService) WriteSnapshot(w io.Writer) (int, error) {
+	// Copy the entries under the lock and encode them without it.
+	c.mu.Lock()
+	now := time.Now()
+	items := make([]cacheItem, 0, c.lru.Len())
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		item := elem.Value.(*cacheItem)
+		if now.After(item.expireTime.Add(c.staleGrace)) {
+			continue
+		}
+		items = append(items, *item)
+	}
+	c.mu.Unlock()
+
+	// Bodies are written as they were received, not with HTML characters escaped.
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	written := 0
+	for _, item := range items {
+		value, err := encodeCachedValue(item.value)
+		if err != nil {
+			slog.Warn("Skipping key in cache snapshot", "key", item.key, "error", err)
+			continue
+		}
+		record := snapshotRecord{Key: item.key, StoredAt: item.storedAt, ExpireTime: item.expireTime, Value: value}
+		if err := encoder.Encode(record); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+func (c *localCacheService) ReadSnapshot(r io.Reader) (int, error) {
+	decoder := json.NewDecoder(r)
+	var records []snapshotRecord
+	for {
+		var record snapshotRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		records = append(records, record)
+	}
+
+	// Snapshots list the most recently used entries first; restore them last so they
+	// end up at the front of the LRU list.
+	now := time.Now()
+	restored := 0
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if now.After(record.ExpireTime.Add(c.staleGrace)) {
+			continue
+		}
+		c.setEntry(record.Key, &CacheEntry{
+			Value:      newStoredResponse(record.Key, record.Value, c.gzipMinBytes),
+			StoredAt:   record.StoredAt,
+			ExpireTime: record.ExpireTime,
+		})
+		restored++
+	}
+	return restored, nil
+}
+
+func (c *tieredCacheService) WriteSnapshot(w io.Writer) (int, error) {
+	return c.l1.WriteSnapshot(w)
+}
+
+func (c *tieredCacheService) ReadSnapshot(r io.Reader) (int, error) {
+	return c.l1.ReadSnapshot(r)
+}
+
+// encodeCachedValue returns the JSON encoding of a cached value.
+func encodeCachedValue(value interface{}) ([]byte, error) {
+	if raw, ok := value.(*RawResponse); ok {
+		return raw.Body(), nil
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+func TestSnapshotRestoresBodiesAsReceived(t *testing.T) {
+	cfg := config.Default().Cache
+	cfg.Passthrough = config.PassthroughConfig{Enabled: true, GzipMinBytes: 16}
+	body := []byte(`{"msg":"<b>maintenance</b> & upgrade","code":0}`)
+	original := newRawResponse(body)
+	saved := newLocalCacheService(cfg)
+	saved.Set("spot_exchangeInfo:", original, time.Minute)
+
+	var snapshot bytes.Buffer
+	if written, err := saved.WriteSnapshot(&snapshot); err != nil || written != 1 {
+		t.Fatalf("WriteSnapshot = %d, %v, want 1 entry", written, err)
+	}
+	restored := newLocalCacheService(cfg)
+	if read, err := restored.ReadSnapshot(&snapshot); err != nil || read != 1 {
+		t.Fatalf("ReadSnapshot = %d, %v, want 1 entry", read, err)
+	}
+
+	value, ok := restored.Get("spot_exchangeInfo:")
+	if !ok {
+		t.Fatal("the restored entry is missing")
+	}
+	raw := value.(*RawResponse)
+	if !bytes.Equal(raw.Body(), body) {
+		t.Fatalf("restored body = %s, want %s", raw.Body(), body)
+	}
+	if raw.ETag() != original.ETag() {
+		t.Fatalf("restored ETag = %s, want %s", raw.ETag(), original.ETag())
+	}
+	if _, ok := raw.Gzipped(); !ok {
+		t.Fatal("the restored body has no gzip copy")
+	}
+}
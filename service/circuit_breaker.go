@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen is returned when a call is refused because the upstream's breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitOpenError is returned instead of calling an upstream whose breaker is open.
+type CircuitOpenError struct {
+	Upstream   string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("binance %s: %v, retry after %s", e.Upstream, ErrCircuitOpen, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitBreakerRegistry holds one circuit breaker per upstream host and reports their state.
+type CircuitBreakerRegistry interface {
+	Register(upstream string, cfg config.CircuitBreakerConfig)
+	States() map[string]CircuitBreakerState
+	breaker(upstream string) *circuitBreaker
+}
+
+// CircuitBreakerState reports the state of one upstream's breaker.
+type CircuitBreakerState struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+	Opens               uint64     `json:"opens"`
+	Rejected            uint64     `json:"rejected"`
+	Retries             uint64     `json:"retries"`
+}
+
+type circuitBreakerRegistry struct {
+	mu       sync.RWMutex
+	breakers map[string]*circuitBreaker
+}
+
+// NewCircuitBreakerRegistry creates and returns a new CircuitBreakerRegistry instance.
+func NewCircuitBreakerRegistry() CircuitBreakerRegistry {
+	return &circuitBreakerRegistry{
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+func (r *circuitBreakerRegistry) Register(upstream string, cfg config.CircuitBreakerConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakers[upstream] = &circuitBreaker{name: upstream, cfg: cfg, state: BreakerClosed}
+}
+
+func (r *circuitBreakerRegistry) breaker(upstream string) *circuitBreaker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.breakers[upstream]
+}
+
+func (r *circuitBreakerRegistry) States() map[string]CircuitBreakerState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	states := make(map[string]CircuitBreakerState, len(r.breakers))
+	for name, b := range r.breakers {
+		states[name] = b.snapshot()
+	}
+	return states
+}
+
+// breakerOutcome is the result of a call as far as the breaker is concerned.
+type breakerOutcome int
+
+const (
+	outcomeSuccess breakerOutcome = iota
+	outcomeFailure
+	// outcomeIgnored is used for calls that say nothing about the upstream's health,
+	// e.g. cancelled by the client or rejected as a bad request.
+	outcomeIgnored
+)
+
+// circuitBreaker stops calls to an upstream after FailureThreshold consecutive failures,
+// lets HalfOpenMaxCalls probe calls through once OpenTimeout has passed, and closes again
+// when a probe succeeds.
+type circuitBreaker struct {
+	name string
+	cfg  config.CircuitBreakerConfig
+
+	mu                  sync.Mutex
+	state               string
+	consecutiveFailures int
+	openedAt            time.Time
+	halfOpenInFlight    int
+	opens               uint64
+	rejected            uint64
+	retries             uint64
+}
+
+// allow reports whether a call may be made. Every allowed call must be followed by record.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if wait := time.Until(b.openedAt.Add(b.cfg.OpenTimeout.Duration())); wait > 0 {
+			b.rejected++
+			return &CircuitOpenError{Upstream: b.name, RetryAfter: wait}
+		}
+		b.state = BreakerHalfOpen
+		b.halfOpenInFlight = 0
+	}
+	if b.state == BreakerHalfOpen {
+		if b.halfOpenInFlight >= b.cfg.HalfOpenMaxCalls {
+			b.rejected++
+			return &CircuitOpenError{Upstream: b.name, RetryAfter: b.cfg.OpenTimeout.Duration()}
+		}
+		b.halfOpenInFlight++
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an allowed call.
+func (b *circuitBreaker) record(outcome breakerOutcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.halfOpenInFlight > 0 {
+		b.halfOpenInFlight--
+	}
+	switch outcome {
+	case outcomeSuccess:
+		b.consecutiveFailures = 0
+		b.state = BreakerClosed
+	case outcomeFailure:
+		b.consecutiveFailures++
+		if b.state == BreakerHalfOpen || b.consecutiveFailures >= b.cfg.FailureThreshold {
+			if b.state != BreakerOpen {
+				b.opens++
+			}
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+		}
+	}
+}
+
+func (b *circuitBreaker) recordRetry() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.retries++
+}
+
+func (b *circuitBreaker) snapshot() CircuitBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := CircuitBreakerState{
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		Opens:               b.opens,
+		Rejected:            b.rejected,
+		Retries:             b.retries,
+	}
+	if b.state == BreakerOpen && time.Until(b.openedAt.Add(b.cfg.OpenTimeout.Duration())) <= 0 {
+		// The next call will be let through as a probe.
+		state.State = BreakerHalfOpen
+	}
+	if !b.openedAt.IsZero() && state.State != BreakerClosed {
+		openedAt := b.openedAt
+		state.OpenedAt = &openedAt
+	}
+	return state
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// decodeFunc turns a raw upstream response body into a typed model value.
+type decodeFunc func(body []byte) (interface{}, error)
+
+// cacheGetter is the getWithCache signature shared by the spot and futures services.
+type cacheGetter func(ctx context.Context, cacheName, keySuffix, apiURL string, params map[string]string, decode decodeFunc) (interface{}, error)
+
+// decodeJSON returns a decodeFunc that unmarshals the body into a value of type T.
+func decodeJSON[T any]() decodeFunc {
+	return func(body []byte) (interface{}, error) {
+		var v T
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// getTyped runs a cached lookup and returns the result decoded into T. With a
+// pass-through context the raw response is handed to the Passthrough instead and T is
+// left zero.
+func getTyped[T any](ctx context.Context, get cacheGetter, cacheName, keySuffix, apiURL string, params map[string]string) (T, error) {
+	var zero T
+	value, err := get(ctx, cacheName, keySuffix, apiURL, params, decodeJSON[T]())
+	if err != nil {
+		return zero, err
+	}
+	if passthrough, ok := PassthroughFromContext(ctx); ok {
+		if raw, ok := value.(*RawResponse); ok {
+			passthrough.set(raw)
+			return zero, nil
+		}
+	}
+	v, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("unexpected cached value type %T for %s:%s, want %T", value, cacheName, keySuffix, zero)
+	}
+	return v, nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// HealthChecker decides whether the server is ready for traffic and reports the state of
+// every upstream behind that decision.
+type HealthChecker interface {
+	// Ready runs the readiness checks: the cache has been warmed and enough of every
+	// rate-limit budget is left. Depending on the upstream readiness setting, open
+	// circuit breakers and high upstream error rates fail it too; otherwise they are
+	// only reported by Status.
+	Ready() Readiness
+	// Status returns the readiness together with a breakdown per upstream.
+	Status() HealthStatus
+}
+
+// Readiness is the outcome of the readiness checks.
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// ReadinessCheck is the outcome of one readiness check.
+type ReadinessCheck struct {
+	Name     string `json:"name"`
+	Upstream string `json:"upstream,omitempty"`
+	OK       bool   `json:"ok"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// HealthStatus is the detailed state reported by the status endpoint.
+type HealthStatus struct {
+	Readiness
+	Cache     RefreshSchedulerStats     `json:"cache"`
+	Upstreams map[string]UpstreamHealth `json:"upstreams"`
+}
+
+// UpstreamHealth is the state of one upstream.
+type UpstreamHealth struct {
+	Calls          UpstreamCallStatus  `json:"calls"`
+	CircuitBreaker CircuitBreakerState `json:"circuitBreaker"`
+	RateLimit      RateLimitState      `json:"rateLimit"`
+	Clock          UpstreamClockStats  `json:"clock"`
+}
+
+type healthChecker struct {
+	cfg       config.HealthConfig
+	scheduler RefreshScheduler
+	breakers  CircuitBreakerRegistry
+	governor  RateLimitGovernor
+	monitor   UpstreamMonitor
+	clock     UpstreamClock
+}
+
+// NewHealthChecker creates and returns a new HealthChecker.
+func NewHealthChecker(cfg config.HealthConfig, scheduler RefreshScheduler, breakers CircuitBreakerRegistry, governor RateLimitGovernor, monitor UpstreamMonitor, clock UpstreamClock) HealthChecker {
+	return &healthChecker{
+		cfg:       cfg,
+		scheduler: scheduler,
+		breakers:  breakers,
+		governor:  governor,
+		monitor:   monitor,
+		clock:     clock,
+	}
+}
+
+func (h *healthChecker) Ready() Readiness {
+	return h.ready(h.scheduler.Stats(), h.breakers.States(), h.governor.Stats(), h.monitor.Status())
+}
+
+func (h *healthChecker) Status() HealthStatus {
+	cache := h.scheduler.Stats()
+	breakers := h.breakers.States()
+	budgets := h.governor.Stats()
+	calls := h.monitor.Status()
+	clocks := h.clock.Stats()
+
+	upstreams := make(map[string]UpstreamHealth, len(breakers))
+	for name, breaker := range breakers {
+		upstreams[name] = UpstreamHealth{
+			Calls:          calls[name],
+			CircuitBreaker: breaker,
+			RateLimit:      budgets[name],
+			Clock:          clocks[name],
+		}
+	}
+	return HealthStatus{
+		Readiness: h.ready(cache, breakers, budgets, calls),
+		Cache:     cache,
+		Upstreams: upstreams,
+	}
+}
+
+func (h *healthChecker) ready(cache RefreshSchedulerStats, breakers map[string]CircuitBreakerState, budgets map[string]RateLimitState, calls map[string]UpstreamCallStatus) Readiness {
+	var checks []ReadinessCheck
+	if h.cfg.RequireWarm {
+		check := ReadinessCheck{Name: "cacheWarm", OK: cache.Warmed}
+		if !cache.Warmed {
+			check.Detail = "the warm list is still being fetched"
+		}
+		checks = append(checks, check)
+	}
+
+	names := make([]string, 0, len(breakers))
+	for name := range breakers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := time.Now()
+	var failing []string
+	for _, name := range names {
+		budget := budgets[name]
+		rateLimit := ReadinessCheck{Name: "rateLimit", Upstream: name, OK: true}
+		if budget.BackoffUntil != nil && budget.BackoffUntil.After(now) {
+			rateLimit.OK = false
+			rateLimit.Detail = fmt.Sprintf("calls are suspended until %s", budget.BackoffUntil.Format(time.RFC3339))
+		} else if budget.Budget > 0 {
+			remaining := float64(budget.Budget-budget.UsedWeight) / float64(budget.Budget)
+			if remaining < h.cfg.MinBudgetShare {
+				rateLimit.OK = false
+				rateLimit.Detail = fmt.Sprintf("%.0f%% of the weight budget is left", max(remaining, 0)*100)
+			}
+		}
+		checks = append(checks, rateLimit)
+
+		if h.cfg.UpstreamReadiness == "none" {
+			continue
+		}
+		breaker := ReadinessCheck{Name: "circuitBreaker", Upstream: name, OK: breakers[name].State != BreakerOpen}
+		if !breaker.OK {
+			breaker.Detail = "circuit breaker is open"
+		}
+		recent := calls[name]
+		errorRate := ReadinessCheck{Name: "errorRate", Upstream: name, OK: true}
+		if recent.RecentCalls >= h.cfg.MinCalls && recent.ErrorRate > h.cfg.MaxErrorRate {
+			errorRate.OK = false
+			errorRate.Detail = fmt.Sprintf("%d of the last %d calls failed", recent.RecentFailures, recent.RecentCalls)
+		}
+		if h.cfg.UpstreamReadiness == "any" {
+			checks = append(checks, breaker, errorRate)
+		} else if !breaker.OK || !errorRate.OK {
+			failing = append(failing, name)
+		}
+	}
+	if h.cfg.UpstreamReadiness == "all" && len(names) > 0 {
+		upstreams := ReadinessCheck{Name: "upstreams", OK: len(failing) < len(names)}
+		if !upstreams.OK {
+			upstreams.Detail = "every upstream has an open circuit breaker or a high error rate"
+		}
+		checks = append(checks, upstreams)
+	}
+
+	ready := true
+	for _, check := range checks {
+		ready = ready && check.OK
+	}
+	return Readiness{Ready: ready, Checks: checks}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+func TestReadinessUpstreamFailures(t *testing.T) {
+	open := CircuitBreakerState{State: BreakerOpen}
+	closed := CircuitBreakerState{State: BreakerClosed}
+	failingCalls := UpstreamCallStatus{RecentCalls: 20, RecentFailures: 20, ErrorRate: 1}
+	cache := RefreshSchedulerStats{Warmed: true}
+
+	tests := []struct {
+		mode     string
+		breakers map[string]CircuitBreakerState
+		calls    map[string]UpstreamCallStatus
+		ready    bool
+	}{
+		// The default fails readiness once every upstream is failing.
+		{"", map[string]CircuitBreakerState{"spot": open, "futures": open}, nil, false},
+		{"", map[string]CircuitBreakerState{"spot": open, "futures": closed}, map[string]UpstreamCallStatus{"futures": failingCalls}, false},
+		{"", map[string]CircuitBreakerState{"spot": open, "futures": closed}, nil, true},
+		{"none", map[string]CircuitBreakerState{"spot": open, "futures": open}, nil, true},
+		{"all", map[string]CircuitBreakerState{"spot": open, "futures": closed}, nil, true},
+		{"all", map[string]CircuitBreakerState{"spot": closed, "futures": closed}, map[string]UpstreamCallStatus{"spot": failingCalls}, true},
+		{"all", map[string]CircuitBreakerState{"spot": open, "futures": closed}, map[string]UpstreamCallStatus{"futures": failingCalls}, false},
+		{"any", map[string]CircuitBreakerState{"spot": closed, "futures": closed}, map[string]UpstreamCallStatus{"spot": failingCalls}, false},
+		{"any", map[string]CircuitBreakerState{"spot": open, "futures": closed}, nil, false},
+		{"any", map[string]CircuitBreakerState{"spot": closed, "futures": closed}, nil, true},
+	}
+	for _, test := range tests {
+		cfg := config.Default().Health
+		if test.mode != "" {
+			cfg.UpstreamReadiness = test.mode
+		}
+		h := &healthChecker{cfg: cfg}
+		readiness := h.ready(cache, test.breakers, nil, test.calls)
+		if readiness.Ready != test.ready {
+			t.Errorf("%s with breakers %v and calls %v: ready = %v, want %v (checks %+v)", test.mode, test.breakers, test.calls, readiness.Ready, test.ready, readiness.Checks)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// NewHTTPClient creates the HTTP client shared by all upstream services. It has no
+// overall timeout; each call carries its own deadline through its context.
+func NewHTTPClient(cfg config.HTTPConfig) *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.DialTimeout.Duration(),
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout.Duration(),
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout.Duration(),
+		IdleConnTimeout:       cfg.IdleConnTimeout.Duration(),
+		MaxIdleConns:          cfg.MaxIdleConnsPerHost * 2,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+	}
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+	"github.com/ntdat104/go-crypto/model"
+)
+
+const (
+	// maxLimitFamilies bounds the number of symbols whose requested limits are tracked.
+	maxLimitFamilies = 10000
+	// maxFamilyLimits bounds the number of distinct limits tracked per symbol.
+	maxFamilyLimits = 16
+)
+
+// limitFamily tracks the lookups of one symbol that only differ by limit, e.g. the spot
+// depth of BTCUSDT at limits 10 and 100.
+type limitFamily struct {
+	// requests counts the requests per limit in the current window.
+	requests    map[int]int
+	total       int
+	windowStart time.Time
+	lastSeen    time.Time
+}
+
+// limitIndex remembers which limits are requested per family, so that lookups can be
+// answered from the cached response of a larger limit and hot families can be fetched
+// at the largest commonly requested limit.
+type limitIndex struct {
+	cfg config.ContainmentConfig
+
+	mu       sync.Mutex
+	families map[string]*limitFamily
+}
+
+func newLimitIndex(cfg config.ContainmentConfig) *limitIndex {
+	return &limitIndex{
+		cfg:      cfg,
+		families: make(map[string]*limitFamily),
+	}
+}
+
+// observe records a request for limit in family. It returns the other known limits of
+// the family larger than limit, ascending, and the limit to fetch on a miss, which is
+// widened beyond limit for hot families.
+func (x *limitIndex) observe(family string, limit int) ([]int, int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	now := time.Now()
+	window := x.cfg.Window.Duration()
+
+	f, ok := x.families[family]
+	if !ok {
+		if len(x.families) >= maxLimitFamilies {
+			x.sweep(now)
+		}
+		if len(x.families) >= maxLimitFamilies {
+			return nil, limit
+		}
+		f = &limitFamily{requests: make(map[int]int), windowStart: now}
+		x.families[family] = f
+	}
+	f.lastSeen = now
+	if now.Sub(f.windowStart) > window {
+		// Keep the known limits for lookups, but count the new window from zero.
+		for l := range f.requests {
+			f.requests[l] = 0
+		}
+		f.total = 0
+		f.windowStart = now
+	}
+	if _, known := f.requests[limit]; known || len(f.requests) < maxFamilyLimits {
+		f.requests[limit]++
+	}
+	f.total++
+
+	var larger []int
+	fetchLimit := limit
+	hot := x.cfg.HotRequests > 0 && f.total >= x.cfg.HotRequests
+	for l, n := range f.requests {
+		if l <= limit {
+			continue
+		}
+		larger = append(larger, l)
+		if hot && l > fetchLimit && float64(n) >= x.cfg.MinShare*float64(f.total) {
+			fetchLimit = l
+		}
+	}
+	slices.Sort(larger)
+	return larger, fetchLimit
+}
+
+// sweep drops the families not requested within the window.
+func (x *limitIndex) sweep(now time.Time) {
+	window := x.cfg.Window.Duration()
+	for family, f := range x.families {
+		if now.Sub(f.lastSeen) > window {
+			delete(x.families, family)
+		}
+	}
+}
+
+// getContained looks up family at limit through get, whose key suffix is
+// "<family>-<limit>". A fresh cached response for the exact limit is used as it is;
+// otherwise a fresh cached response with a larger limit is cut down with slice, and on a
+// miss the fetch is widened to the limit chosen by the limit index. If that fetch fails
+// the way stale-if-error covers, an expired response with a larger limit is cut down
+// and served as stale. Responses cut down from another limit are never passed through,
+// as their body does not match the request.
+func getContained[T any](ctx context.Context, u *upstream, cacheName, family string, limit int, get func(ctx context.Context, limit int) (T, error), slice func(v T, limit int) T) (T, error) {
+	if u.limits == nil || limit <= 0 {
+		return get(ctx, limit)
+	}
+	larger, fetchLimit := u.limits.observe(u.cacheKey(cacheName, family), limit)
+	if u.cache.Has(u.cacheKey(cacheName, fmt.Sprintf("%s-%d", family, limit))) {
+		return get(ctx, limit)
+	}
+	for _, l := range larger {
+		if u.cache.Has(u.cacheKey(cacheName, fmt.Sprintf("%s-%d", family, l))) {
+			return getSliced(ctx, l, limit, get, slice)
+		}
+	}
+	var v T
+	var err error
+	if fetchLimit > limit {
+		v, err = getSliced(ctx, fetchLimit, limit, get, slice)
+	} else {
+		v, err = get(ctx, limit)
+	}
+	if err != nil && ctx.Err() == nil && canServeStale(err) {
+		if stale, ok := getStaleContained(ctx, u, cacheName, family, limit, larger, slice); ok {
+			return stale, nil
+		}
+	}
+	return v, err
+}
+
+// getStaleContained cuts down the first expired, but still cached, response of family
+// with one of the larger limits, and records the lookup as stale.
+func getStaleContained[T any](ctx context.Context, u *upstream, cacheName, family string, limit int, larger []int, slice func(v T, limit int) T) (T, bool) {
+	var zero T
+	for _, l := range larger {
+		key := u.cacheKey(cacheName, fmt.Sprintf("%s-%d", family, l))
+		entry, found := u.cache.GetEntry(key)
+		if !found {
+			continue
+		}
+		req := cacheRequest{cacheName: cacheName, key: key, delayKey: key + delayKeySuffix, decode: decodeJSON[T]()}
+		value, err := u.resolve(withoutPassthrough(ctx), req, entry.Value)
+		if err != nil {
+			continue
+		}
+		v, ok := value.(T)
+		if !ok {
+			continue
+		}
+		u.recordResult(ctx, req, CacheStale, entry)
+		return slice(v, limit), true
+	}
+	return zero, false
+}
+
+func getSliced[T any](ctx context.Context, from, limit int, get func(ctx context.Context, limit int) (T, error), slice func(v T, limit int) T) (T, error) {
+	v, err := get(withoutPassthrough(ctx), from)
+	if err != nil {
+		return v, err
+	}
+	return slice(v, limit), nil
+}
+
+// sliceDepth returns the best limit levels of each side of the book.
+func sliceDepth(depth *model.DepthSnapshot, limit int) *model.DepthSnapshot {
+	sliced := *depth
+	// The levels are shared with the cached value, so the slices are capped to keep
+	// appends from writing into it.
+	bids, asks := min(limit, len(depth.Bids)), min(limit, len(depth.Asks))
+	sliced.Bids = depth.Bids[:bids:bids]
+	sliced.Asks = depth.Asks[:asks:asks]
+	return &sliced
+}
+
+// sliceKlines returns the latest limit klines.
+func sliceKlines(klines []model.Kline, limit int) []model.Kline {
+	return klines[max(len(klines)-limit, 0):len(klines):len(klines)]
+}
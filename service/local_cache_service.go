@@ -1,35 +1,61 @@
 package service
 
 import (
-	"log"
+	"container/list"
+	"strings"
 	"sync"
 	"time"
-)
 
-type LocalCacheService interface {
-	Set(key string, value interface{}, ttl time.Duration)
-	Get(key string) (interface{}, bool)
-	GetExpireTime(key string) (*time.Time, bool)
-	Del(key string)
-	Has(key string) bool
-}
+	"github.com/ntdat104/go-crypto/config"
+)
 
 type cacheItem struct {
+	key        string
 	value      interface{}
+	size       int64
+	storedAt   time.Time
 	expireTime time.Time
 }
 
+// localCacheService is an in-memory cache bounded by entry count and approximate size.
+// Entries are kept in least recently used order and evicted from the back when either
+// limit is exceeded.
 type localCacheService struct {
-	store sync.Map
+	mu         sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List
+	bytes      int64
+	maxEntries int
+	maxBytes   int64
+	staleGrace time.Duration
+	// gzipMinBytes is used to rebuild the gzip copies of snapshot entries.
+	gzipMinBytes int
+	hits         uint64
+	misses       uint64
+	evictions    uint64
+	expirations  uint64
+	// evictionsByName counts evictions per cache name, see CacheStats.EvictionsByName.
+	evictionsByName map[string]uint64
 }
 
-func NewLocalCacheService() LocalCacheService {
+// NewLocalCacheService creates and returns the in-memory CacheBackend.
+func NewLocalCacheService(cfg config.CacheConfig) CacheBackend {
+	return newLocalCacheService(cfg)
+}
+
+func newLocalCacheService(cfg config.CacheConfig) *localCacheService {
 	c := &localCacheService{
-		store: sync.Map{},
+		items:           make(map[string]*list.Element),
+		lru:             list.New(),
+		evictionsByName: make(map[string]uint64),
+		maxEntries:      cfg.MaxEntries,
+		maxBytes:        cfg.MaxBytes,
+		staleGrace:      cfg.StaleIfErrorGrace.Duration(),
+		gzipMinBytes:    gzipMinBytesOf(cfg.Passthrough),
 	}
 	// Start cleanup ticker
 	go func() {
-		ticker := time.NewTicker(10 * time.Minute)
+		ticker := time.NewTicker(cfg.CleanupInterval.Duration())
 		defer ticker.Stop()
 		for range ticker.C {
 			c.cleanUp()
@@ -39,54 +65,189 @@ func NewLocalCacheService() LocalCacheService {
 }
 
 func (c *localCacheService) Set(key string, value interface{}, ttl time.Duration) {
-	expire := time.Now().Add(ttl)
-	c.store.Store(key, cacheItem{
-		value:      value,
-		expireTime: expire,
-	})
+	now := time.Now()
+	c.setEntry(key, &CacheEntry{Value: value, StoredAt: now, ExpireTime: now.Add(ttl)})
+}
+
+// setEntry stores entry as is, keeping its stored and expire times.
+func (c *localCacheService) setEntry(key string, entry *CacheEntry) {
+	item := &cacheItem{
+		key:        key,
+		value:      entry.Value,
+		storedAt:   entry.StoredAt,
+		expireTime: entry.ExpireTime,
+	}
+	// A raw response grows when it is first decoded, typically after it was stored.
+	var size int64
+	if raw, ok := entry.Value.(*RawResponse); ok {
+		size = raw.trackSize(func(delta int64) { c.grow(item, delta) })
+	} else {
+		size = approximateSize(entry.Value)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item.size += entryOverhead + int64(len(key)) + size
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	c.items[key] = c.lru.PushFront(item)
+	c.bytes += item.size
+	c.evict()
+}
+
+// grow adds delta bytes to the size of item, evicting entries if the cache is now over
+// its bounds.
+func (c *localCacheService) grow(item *cacheItem, delta int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item.size += delta
+	if elem, ok := c.items[item.key]; !ok || elem.Value != item {
+		// Not stored yet, in which case setEntry counts the new size, or removed already.
+		return
+	}
+	c.bytes += delta
+	c.evict()
+}
+
+// evict removes least recently used entries until the cache is within its bounds. The
+// caller must hold c.mu.
+func (c *localCacheService) evict() {
+	for c.lru.Len() > c.maxEntries || (c.bytes > c.maxBytes && c.lru.Len() > 1) {
+		evicted := c.remove(c.lru.Back())
+		c.evictions++
+		if !strings.HasSuffix(evicted.key, delayKeySuffix) {
+			c.evictionsByName[cacheNameOf(evicted.key)]++
+		}
+	}
 }
 
 func (c *localCacheService) GetExpireTime(key string) (*time.Time, bool) {
-	val, ok := c.store.Load(key)
-	if !ok {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.load(key, time.Now())
+	if !ok || time.Now().After(item.expireTime) {
 		return nil, false
 	}
-
-	item := val.(cacheItem)
-	return &item.expireTime, true
+	expireTime := item.expireTime
+	return &expireTime, true
 }
 
 func (c *localCacheService) Get(key string) (interface{}, bool) {
-	val, ok := c.store.Load(key)
-	if !ok {
+	entry, ok := c.GetEntry(key)
+	if !ok || entry.Expired() {
 		return nil, false
 	}
+	return entry.Value, true
+}
 
-	item := val.(cacheItem)
-	if time.Now().After(item.expireTime) {
-		c.store.Delete(key)
+func (c *localCacheService) GetEntry(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	item, ok := c.load(key, now)
+	if !ok || now.After(item.expireTime) {
+		c.misses++
+	} else {
+		c.hits++
+	}
+	if !ok {
 		return nil, false
 	}
-	return item.value, true
+	c.lru.MoveToFront(c.items[key])
+	return &CacheEntry{
+		Value:      item.value,
+		StoredAt:   item.storedAt,
+		ExpireTime: item.expireTime,
+	}, true
 }
 
 func (c *localCacheService) Del(key string) {
-	c.store.Delete(key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
 }
 
+// Has reports whether key holds a fresh entry. Unlike Get it neither counts as a
+// lookup nor marks the entry as recently used.
 func (c *localCacheService) Has(key string) bool {
-	_, exists := c.Get(key)
-	return exists
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	item, ok := c.load(key, now)
+	return ok && !now.After(item.expireTime)
+}
+
+func (c *localCacheService) Keys(prefix string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for key := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (c *localCacheService) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	evictionsByName := make(map[string]uint64, len(c.evictionsByName))
+	for name, n := range c.evictionsByName {
+		evictionsByName[name] = n
+	}
+	return CacheStats{
+		Backend:         CacheBackendMemory,
+		Entries:         c.lru.Len(),
+		Bytes:           c.bytes,
+		MaxEntries:      c.maxEntries,
+		MaxBytes:        c.maxBytes,
+		Hits:            c.hits,
+		Misses:          c.misses,
+		Evictions:       c.evictions,
+		EvictionsByName: evictionsByName,
+		Expirations:     c.expirations,
+	}
+}
+
+// load returns the item for key, dropping it if it is past its stale grace window.
+// The caller must hold c.mu.
+func (c *localCacheService) load(key string, now time.Time) (*cacheItem, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*cacheItem)
+	if now.After(item.expireTime.Add(c.staleGrace)) {
+		c.remove(elem)
+		c.expirations++
+		return nil, false
+	}
+	return item, true
+}
+
+// remove drops elem from the cache and returns its item. The caller must hold c.mu.
+func (c *localCacheService) remove(elem *list.Element) *cacheItem {
+	item := c.lru.Remove(elem).(*cacheItem)
+	delete(c.items, item.key)
+	c.bytes -= item.size
+	return item
 }
 
 func (c *localCacheService) cleanUp() {
-	log.Println("========== clean up cache ==========")
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	now := time.Now()
-	c.store.Range(func(key, val interface{}) bool {
-		item := val.(cacheItem)
-		if now.After(item.expireTime) {
-			c.store.Delete(key)
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		item := elem.Value.(*cacheItem)
+		if now.After(item.expireTime.Add(c.staleGrace)) {
+			c.remove(elem)
+			c.expirations++
 		}
-		return true
-	})
+		elem = prev
+	}
 }
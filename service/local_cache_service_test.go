@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+	"github.com/ntdat104/go-crypto/model"
+)
+
+func TestLocalCacheCountsDecodedResponses(t *testing.T) {
+	cfg := config.Default().Cache
+	cache := newLocalCacheService(cfg)
+	raw := newRawResponse([]byte(`[{"symbol":"BTCUSDT","price":"65000.00"},{"symbol":"ETHUSDT","price":"3200.00"}]`))
+	cache.Set("spot_allPrices:", raw, time.Minute)
+	before := cache.Stats().Bytes
+
+	if _, err := raw.decode(decodeJSON[[]model.TickerPrice]()); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if after := cache.Stats().Bytes; after <= before {
+		t.Fatalf("bytes after decode = %d, want more than %d", after, before)
+	}
+}
+
+func TestLocalCacheEvictsWhenDecodeExceedsMaxBytes(t *testing.T) {
+	cfg := config.Default().Cache
+	cache := newLocalCacheService(cfg)
+	first := newRawResponse([]byte(`[{"symbol":"BTCUSDT","price":"65000.00"}]`))
+	second := newRawResponse([]byte(`[{"symbol":"ETHUSDT","price":"3200.00"}]`))
+	cache.Set("spot_allPrices:a", first, time.Minute)
+	cache.Set("spot_allPrices:b", second, time.Minute)
+	// Leave room for both entries as stored, but not once one of them is decoded.
+	cache.mu.Lock()
+	cache.maxBytes = cache.bytes
+	cache.mu.Unlock()
+
+	if _, err := second.decode(decodeJSON[[]model.TickerPrice]()); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	stats := cache.Stats()
+	if stats.Bytes > stats.MaxBytes {
+		t.Fatalf("bytes = %d, over the bound of %d", stats.Bytes, stats.MaxBytes)
+	}
+	if _, ok := cache.Get("spot_allPrices:a"); ok {
+		t.Fatal("least recently used entry was not evicted")
+	}
+	if _, ok := cache.Get("spot_allPrices:b"); !ok {
+		t.Fatal("decoded entry was evicted")
+	}
+}
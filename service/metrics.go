@@ -0,0 +1,195 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes every metric name.
+const metricsNamespace = "gocrypto"
+
+// Metrics exposes Prometheus metrics for the HTTP API, the upstream calls, the cache, the
+// background refreshes and the rate-limit budgets. Counters that the components keep
+// for their stats endpoints are read from those stats on every scrape rather than
+// counted twice.
+type Metrics interface {
+	// Handler serves the metrics in the Prometheus exposition format.
+	Handler() http.Handler
+	// ObserveRequest records one API request. route is the route pattern, not the
+	// request path, to keep the number of series bounded.
+	ObserveRequest(route, method string, status int, duration time.Duration)
+
+	// observeUpstreamCall records one upstream attempt. status is 0 when no response
+	// was received.
+	observeUpstreamCall(upstream, path string, status int, duration time.Duration)
+	// observeRefresh records the outcome of one background refresh.
+	observeRefresh(cacheName string, err error)
+}
+
+type metrics struct {
+	registry         *prometheus.Registry
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	upstreamCalls    *prometheus.CounterVec
+	upstreamDuration *prometheus.HistogramVec
+	refreshes        *prometheus.CounterVec
+}
+
+// NewMetrics creates and returns a new Metrics instance reading the stats of the given
+// components on every scrape.
+func NewMetrics(cache CacheBackend, admin CacheAdmin, refresher BackgroundRefresher, governor RateLimitGovernor) Metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "API requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "API request latency by route and method.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"route", "method"}),
+		upstreamCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "upstream_requests_total",
+			Help:      "Binance call attempts by upstream, path and status code; code is \"error\" when no response was received.",
+		}, []string{"upstream", "path", "code"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Binance call attempt latency by upstream and path.",
+			Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 20},
+		}, []string{"upstream", "path"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_refreshes_total",
+			Help:      "Background refreshes by cache name and outcome.",
+		}, []string{"cache", "outcome"}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.requestDuration, m.upstreamCalls, m.upstreamDuration, m.refreshes,
+		newStatsCollector(cache, admin, refresher, governor),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+func (m *metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *metrics) ObserveRequest(route, method string, status int, duration time.Duration) {
+	m.requests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+func (m *metrics) observeUpstreamCall(upstream, path string, status int, duration time.Duration) {
+	code := "error"
+	if status > 0 {
+		code = strconv.Itoa(status)
+	}
+	m.upstreamCalls.WithLabelValues(upstream, path, code).Inc()
+	m.upstreamDuration.WithLabelValues(upstream, path).Observe(duration.Seconds())
+}
+
+func (m *metrics) observeRefresh(cacheName string, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	m.refreshes.WithLabelValues(cacheName, outcome).Inc()
+}
+
+// statsCollector turns the stats of the cache, the refresher and the rate-limit
+// governor into metrics when scraped.
+type statsCollector struct {
+	cache     CacheBackend
+	admin     CacheAdmin
+	refresher BackgroundRefresher
+	governor  RateLimitGovernor
+
+	cacheLookups     *prometheus.Desc
+	cacheEvictions   *prometheus.Desc
+	cacheEntries     *prometheus.Desc
+	cacheBytes       *prometheus.Desc
+	refreshRequests  *prometheus.Desc
+	refreshesRunning *prometheus.Desc
+	weightUsed       *prometheus.Desc
+	weightBudget     *prometheus.Desc
+	weightLimit      *prometheus.Desc
+}
+
+func newStatsCollector(cache CacheBackend, admin CacheAdmin, refresher BackgroundRefresher, governor RateLimitGovernor) *statsCollector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", name), help, labels, nil)
+	}
+	return &statsCollector{
+		cache:            cache,
+		admin:            admin,
+		refresher:        refresher,
+		governor:         governor,
+		cacheLookups:     desc("cache_lookups_total", "Cache lookups by cache name and result: hit, miss, stale or error.", "cache", "result"),
+		cacheEvictions:   desc("cache_evictions_total", "Entries evicted from the in-memory cache to stay within capacity, by cache name.", "cache"),
+		cacheEntries:     desc("cache_entries", "Entries held by the in-memory cache."),
+		cacheBytes:       desc("cache_bytes", "Approximate size of the entries held by the in-memory cache."),
+		refreshRequests:  desc("refresh_requests_total", "Background refresh requests by result: started, skipped_pending or skipped_busy.", "result"),
+		refreshesRunning: desc("refreshes_running", "Background refreshes currently running, each in its own goroutine."),
+		weightUsed:       desc("ratelimit_weight_used", "Request weight used in the current one-minute window.", "upstream"),
+		weightBudget:     desc("ratelimit_weight_budget", "Request weight this server allows itself per minute.", "upstream"),
+		weightLimit:      desc("ratelimit_weight_limit", "Binance request-weight limit per minute.", "upstream"),
+	}
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cacheLookups
+	ch <- c.cacheEvictions
+	ch <- c.cacheEntries
+	ch <- c.cacheBytes
+	ch <- c.refreshRequests
+	ch <- c.refreshesRunning
+	ch <- c.weightUsed
+	ch <- c.weightBudget
+	ch <- c.weightLimit
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	counter := func(desc *prometheus.Desc, value uint64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), labels...)
+	}
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+
+	for name, s := range c.admin.Stats() {
+		counter(c.cacheLookups, s.Hits, name, "hit")
+		counter(c.cacheLookups, s.Misses, name, "miss")
+		counter(c.cacheLookups, s.Stale, name, "stale")
+		counter(c.cacheLookups, s.Errors, name, "error")
+	}
+	cache := c.cache.Stats()
+	for name, n := range cache.EvictionsByName {
+		counter(c.cacheEvictions, n, name)
+	}
+	gauge(c.cacheEntries, float64(cache.Entries))
+	gauge(c.cacheBytes, float64(cache.Bytes))
+
+	refresh := c.refresher.Stats()
+	counter(c.refreshRequests, refresh.Started, "started")
+	counter(c.refreshRequests, refresh.SkippedPending, "skipped_pending")
+	counter(c.refreshRequests, refresh.SkippedBusy, "skipped_busy")
+	gauge(c.refreshesRunning, float64(refresh.Running))
+
+	for upstream, s := range c.governor.Stats() {
+		gauge(c.weightUsed, float64(s.UsedWeight), upstream)
+		gauge(c.weightBudget, float64(s.Budget), upstream)
+		gauge(c.weightLimit, float64(s.Limit), upstream)
+	}
+}
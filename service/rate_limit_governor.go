@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+const (
+	usedWeightHeader        = "X-Mbx-Used-Weight-1m"
+	orderCountHeaderPrefix  = "X-Mbx-Order-Count-"
+	rateLimitWindowDuration = time.Minute
+)
+
+// RateLimitGovernor keeps each upstream under its Binance request-weight limit. It is
+// shared by the spot and futures services, each of which registers its own budget.
+type RateLimitGovernor interface {
+	// Register sets the budget of an upstream.
+	Register(upstream string, cfg config.RateLimitConfig)
+	// Acquire reserves weight before a call. It waits for the next window when the
+	// budget is spent and the wait is short enough, and otherwise returns a *RateLimitError.
+	Acquire(ctx context.Context, upstream string, weight int) error
+	// Record updates the state from the headers and status of an upstream response.
+	Record(upstream string, statusCode int, header http.Header, retryAfter time.Duration)
+	Stats() map[string]RateLimitState
+}
+
+// RateLimitState reports the rate-limit state of one upstream.
+type RateLimitState struct {
+	// Limit is the Binance request-weight limit per minute.
+	Limit int `json:"limit"`
+	// Budget is the part of Limit this server allows itself to use.
+	Budget int `json:"budget"`
+	// UsedWeight is the weight used in the current window, as last reported by Binance
+	// plus the weight of calls made since.
+	UsedWeight int `json:"usedWeight"`
+	// WindowResetAt is when the current one-minute window ends.
+	WindowResetAt time.Time `json:"windowResetAt"`
+	// OrderCounts holds the last X-MBX-ORDER-COUNT-* values by interval.
+	OrderCounts map[string]int `json:"orderCounts,omitempty"`
+	// BackoffUntil is set while calls are suspended after a 429 or 418.
+	BackoffUntil *time.Time `json:"backoffUntil,omitempty"`
+	// Delayed is the number of calls that waited for the next window.
+	Delayed uint64 `json:"delayed"`
+	// Rejected is the number of calls refused to protect the budget.
+	Rejected uint64 `json:"rejected"`
+	// Throttled is the number of 429 and 418 responses received.
+	Throttled uint64 `json:"throttled"`
+}
+
+// RateLimitError is returned when a call is not sent to protect the rate-limit budget.
+type RateLimitError struct {
+	Upstream   string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("binance %s rate limit: %s, retry after %s", e.Upstream, e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+type rateLimitBucket struct {
+	cfg          config.RateLimitConfig
+	budget       int
+	used         int
+	windowEnd    time.Time
+	orderCounts  map[string]int
+	backoffUntil time.Time
+	delayed      uint64
+	rejected     uint64
+	throttled    uint64
+}
+
+type rateLimitGovernor struct {
+	mu      sync.Mutex
+	buckets map[string]*rateLimitBucket
+}
+
+// NewRateLimitGovernor creates and returns a new RateLimitGovernor instance.
+func NewRateLimitGovernor() RateLimitGovernor {
+	return &rateLimitGovernor{
+		buckets: make(map[string]*rateLimitBucket),
+	}
+}
+
+func (g *rateLimitGovernor) Register(upstream string, cfg config.RateLimitConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.buckets[upstream] = &rateLimitBucket{
+		cfg:         cfg,
+		budget:      int(float64(cfg.WeightPerMinute) * (1 - cfg.SafetyMargin)),
+		orderCounts: make(map[string]int),
+	}
+}
+
+func (g *rateLimitGovernor) Acquire(ctx context.Context, upstream string, weight int) error {
+	for {
+		g.mu.Lock()
+		b, ok := g.buckets[upstream]
+		if !ok {
+			g.mu.Unlock()
+			return nil
+		}
+		now := time.Now()
+		b.roll(now)
+
+		if now.Before(b.backoffUntil) {
+			b.rejected++
+			g.mu.Unlock()
+			return &RateLimitError{Upstream: upstream, Reason: "backing off after a 429/418 response", RetryAfter: b.backoffUntil.Sub(now)}
+		}
+		// A single call heavier than the whole budget is still let through on an empty window.
+		if b.used+weight <= b.budget || b.used == 0 {
+			b.used += weight
+			g.mu.Unlock()
+			return nil
+		}
+
+		wait := b.windowEnd.Sub(now)
+		deadline, hasDeadline := ctx.Deadline()
+		if wait > b.cfg.MaxWait.Duration() || (hasDeadline && now.Add(wait).After(deadline)) {
+			b.rejected++
+			g.mu.Unlock()
+			return &RateLimitError{Upstream: upstream, Reason: "request weight budget exhausted", RetryAfter: wait}
+		}
+		b.delayed++
+		g.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (g *rateLimitGovernor) Record(upstream string, statusCode int, header http.Header, retryAfter time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.buckets[upstream]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	b.roll(now)
+
+	if used, err := strconv.Atoi(header.Get(usedWeightHeader)); err == nil && used > b.used {
+		b.used = used
+	}
+	for name, values := range header {
+		if interval, ok := strings.CutPrefix(name, orderCountHeaderPrefix); ok && len(values) > 0 {
+			if count, err := strconv.Atoi(values[0]); err == nil {
+				b.orderCounts[strings.ToUpper(interval)] = count
+			}
+		}
+	}
+
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusTeapot {
+		b.throttled++
+		if retryAfter <= 0 {
+			retryAfter = b.cfg.BanBackoff.Duration()
+		}
+		if until := now.Add(retryAfter); until.After(b.backoffUntil) {
+			b.backoffUntil = until
+		}
+	}
+}
+
+func (g *rateLimitGovernor) Stats() map[string]RateLimitState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	stats := make(map[string]RateLimitState, len(g.buckets))
+	for upstream, b := range g.buckets {
+		b.roll(now)
+		state := RateLimitState{
+			Limit:         b.cfg.WeightPerMinute,
+			Budget:        b.budget,
+			UsedWeight:    b.used,
+			WindowResetAt: b.windowEnd,
+			OrderCounts:   make(map[string]int, len(b.orderCounts)),
+			Delayed:       b.delayed,
+			Rejected:      b.rejected,
+			Throttled:     b.throttled,
+		}
+		for interval, count := range b.orderCounts {
+			state.OrderCounts[interval] = count
+		}
+		if now.Before(b.backoffUntil) {
+			until := b.backoffUntil
+			state.BackoffUntil = &until
+		}
+		stats[upstream] = state
+	}
+	return stats
+}
+
+// roll starts a new window once the current one has ended. Binance resets the
+// request weight at the start of every minute.
+func (b *rateLimitBucket) roll(now time.Time) {
+	if now.Before(b.windowEnd) {
+		return
+	}
+	b.used = 0
+	b.windowEnd = now.Truncate(rateLimitWindowDuration).Add(rateLimitWindowDuration)
+}
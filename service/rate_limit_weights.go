@@ -0,0 +1,81 @@
+package service
+
+import "strconv"
+
+// weightFunc returns the request weight Binance charges for the endpoint behind cacheName.
+type weightFunc func(cacheName string, params map[string]string) int
+
+// spotWeight follows the weights documented for the spot market data endpoints.
+func spotWeight(cacheName string, params map[string]string) int {
+	_, hasSymbol := params["symbol"]
+	switch cacheName {
+	case "ping", "time":
+		return 1
+	case "exchangeinfo":
+		return 20
+	case "depth":
+		return tieredWeight(limitParam(params, 100), []weightTier{{100, 5}, {500, 25}, {1000, 50}}, 250)
+	case "recenttrades", "historicaltrades":
+		return 25
+	case "aggregatetrades", "klines", "avgprice":
+		return 2
+	case "tickerprice", "bookticker", "ticker24hr":
+		return 2
+	case "alltickerprices", "allbooktickers":
+		return 4
+	}
+	if !hasSymbol {
+		return 80
+	}
+	return 2
+}
+
+// futuresWeight follows the weights documented for the USDⓈ-M futures market data endpoints.
+func futuresWeight(cacheName string, params map[string]string) int {
+	_, hasSymbol := params["symbol"]
+	switch cacheName {
+	case "ping", "time", "exchangeinfo", "tickerprice", "ticker24hr", "markprice", "fundingrate":
+		return 1
+	case "depth":
+		return tieredWeight(limitParam(params, 500), []weightTier{{50, 2}, {100, 5}, {500, 10}}, 20)
+	case "klines":
+		return tieredWeight(limitParam(params, 500), []weightTier{{99, 1}, {499, 2}, {1000, 5}}, 10)
+	case "aggtrades", "allforceorders":
+		return 20
+	case "recenttrades":
+		return 5
+	case "bookticker", "alltickerprices":
+		return 2
+	case "allbooktickers":
+		return 5
+	case "allticker24hr":
+		return 40
+	}
+	if !hasSymbol {
+		return 40
+	}
+	return 1
+}
+
+// weightTier charges weight for requests with a limit up to maxLimit.
+type weightTier struct {
+	maxLimit int
+	weight   int
+}
+
+func tieredWeight(limit int, tiers []weightTier, above int) int {
+	for _, tier := range tiers {
+		if limit <= tier.maxLimit {
+			return tier.weight
+		}
+	}
+	return above
+}
+
+func limitParam(params map[string]string, fallback int) int {
+	limit, err := strconv.Atoi(params["limit"])
+	if err != nil || limit <= 0 {
+		return fallback
+	}
+	return limit
+}
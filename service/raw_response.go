@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"unsafe"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// RawResponse is an upstream response body as received. It is what every backend
+// caches: pass-through responses write it out as it is, and typed callers decode it
+// once, on first use, keeping the result for later hits.
+type RawResponse struct {
+	body    []byte
+	gzipped []byte
+
+	once    sync.Once
+	decoded interface{}
+	err     error
+
+	etagOnce sync.Once
+	etag     string
+
+	// sizeMu guards decodedSize and onDecode, through which the cache entry holding the
+	// response accounts for the memory of the decoded value.
+	sizeMu      sync.Mutex
+	decodedSize int64
+	onDecode    func(delta int64)
+}
+
+// newRawResponse wraps body, which must not be modified afterwards.
+func newRawResponse(body []byte) *RawResponse {
+	return &RawResponse{body: body}
+}
+
+// newDecodedResponse wraps body together with its decoded value, so that it is never
+// decoded again.
+func newDecodedResponse(body []byte, decoded interface{}) *RawResponse {
+	r := newRawResponse(body)
+	r.once.Do(func() {
+		r.decoded = decoded
+		r.decodedSize = approximateSize(decoded)
+	})
+	return r
+}
+
+// Body returns the response body. Callers must not modify it.
+func (r *RawResponse) Body() []byte {
+	return r.body
+}
+
+// Gzipped returns the gzip-compressed body, if one was prepared when it was fetched.
+// Callers must not modify it.
+func (r *RawResponse) Gzipped() ([]byte, bool) {
+	return r.gzipped, r.gzipped != nil
+}
+
+// ETag returns a weak entity tag derived from the body, so that every replica and every
+// refresh that fetched the same payload reports the same tag. It is weak because the
+// body may be sent gzip-compressed or, cut down from a larger response, in part.
+func (r *RawResponse) ETag() string {
+	r.etagOnce.Do(func() {
+		hash := fnv.New64a()
+		hash.Write(r.body)
+		r.etag = fmt.Sprintf(`W/"%016x-%x"`, hash.Sum64(), len(r.body))
+	})
+	return r.etag
+}
+
+// compress prepares the gzip copy of the body. It must be called before the response is
+// shared, i.e. before it is cached.
+func (r *RawResponse) compress() error {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(r.body); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	r.gzipped = buf.Bytes()
+	return nil
+}
+
+// compressIfLarge prepares the gzip copy of a body of at least gzipMinBytes. Zero
+// disables compression.
+func (r *RawResponse) compressIfLarge(gzipMinBytes int) error {
+	if gzipMinBytes <= 0 || len(r.body) < gzipMinBytes {
+		return nil
+	}
+	return r.compress()
+}
+
+// newStoredResponse returns a body read back from a cache store or a snapshot with the
+// gzip copy it had when it was fetched.
+func newStoredResponse(key string, body []byte, gzipMinBytes int) *RawResponse {
+	r := newRawResponse(body)
+	if err := r.compressIfLarge(gzipMinBytes); err != nil {
+		slog.Warn("Failed to compress stored response", "key", key, "error", err)
+	}
+	return r
+}
+
+// gzipMinBytesOf returns the body size from which responses get a gzip copy, which only
+// pass-through responses serve.
+func gzipMinBytesOf(cfg config.PassthroughConfig) int {
+	if !cfg.Enabled {
+		return 0
+	}
+	return cfg.GzipMinBytes
+}
+
+// decode returns the body decoded with decode. Only the first call decodes, and
+// reports the size of the decoded value to the cache entry tracking r, if any.
+func (r *RawResponse) decode(decode decodeFunc) (interface{}, error) {
+	r.once.Do(func() {
+		r.decoded, r.err = decode(r.body)
+		if r.err != nil {
+			return
+		}
+		size := approximateSize(r.decoded)
+		r.sizeMu.Lock()
+		r.decodedSize = size
+		grow := r.onDecode
+		r.onDecode = nil
+		r.sizeMu.Unlock()
+		if grow != nil {
+			grow(size)
+		}
+	})
+	return r.decoded, r.err
+}
+
+// trackSize returns the approximate memory held by r, including the decoded value if r
+// was decoded already, and otherwise has grow called with the size of the decoded
+// value once it is. Only the last caller is told.
+func (r *RawResponse) trackSize(grow func(delta int64)) int64 {
+	r.sizeMu.Lock()
+	defer r.sizeMu.Unlock()
+	if r.decodedSize == 0 {
+		r.onDecode = grow
+	}
+	return int64(unsafe.Sizeof(*r)) + int64(cap(r.body)) + int64(cap(r.gzipped)) + r.decodedSize
+}
+
+// resolveCachedValue returns value decoded with decode if it is a RawResponse, and
+// value itself otherwise.
+func resolveCachedValue(value interface{}, decode decodeFunc) (interface{}, error) {
+	raw, ok := value.(*RawResponse)
+	if !ok {
+		return value, nil
+	}
+	return raw.decode(decode)
+}
+
+// Passthrough collects the raw response behind a request, so that it can be written to
+// the client without decoding and re-encoding it.
+type Passthrough struct {
+	mu  sync.Mutex
+	raw *RawResponse
+}
+
+type passthroughKey struct{}
+
+// WithPassthrough returns a context whose cached lookups are not decoded. Their raw
+// response is collected in the returned Passthrough instead, and the typed result of
+// the service method is left zero, so only callers that write the response to the
+// client as it is may use it.
+func WithPassthrough(ctx context.Context) (context.Context, *Passthrough) {
+	passthrough := &Passthrough{}
+	return context.WithValue(ctx, passthroughKey{}, passthrough), passthrough
+}
+
+// PassthroughFromContext returns the Passthrough installed by WithPassthrough, if any.
+func PassthroughFromContext(ctx context.Context) (*Passthrough, bool) {
+	passthrough, _ := ctx.Value(passthroughKey{}).(*Passthrough)
+	return passthrough, passthrough != nil
+}
+
+// withoutPassthrough returns a context whose lookups are decoded even if ctx has a
+// Passthrough, for results that are not returned as they are.
+func withoutPassthrough(ctx context.Context) context.Context {
+	return context.WithValue(ctx, passthroughKey{}, (*Passthrough)(nil))
+}
+
+// Response returns the collected raw response, if a cached lookup was made.
+func (p *Passthrough) Response() (*RawResponse, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.raw, p.raw != nil
+}
+
+func (p *Passthrough) set(raw *RawResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.raw = raw
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ntdat104/go-crypto/model"
+)
+
+// BenchmarkDecodeAllSymbols measures the decode that a typed response pays once per
+// fetched all-symbol body, on top of the re-encoding measured by the controller
+// benchmarks.
+func BenchmarkDecodeAllSymbols(b *testing.B) {
+	prices := make([]model.TickerPrice, 2500)
+	tickers := make([]model.Ticker24h, 2500)
+	for i := range prices {
+		symbol := fmt.Sprintf("SYM%04dUSDT", i)
+		prices[i] = model.TickerPrice{Symbol: symbol, Price: "65000.12000000"}
+		tickers[i] = model.Ticker24h{
+			Symbol: symbol, PriceChange: "-94.99999800", PriceChangePercent: "-95.960", WeightedAvgPrice: "0.29628482",
+			LastPrice: "4.00000200", LastQty: "200.00000000", OpenPrice: "99.00000000", HighPrice: "100.00000000",
+			LowPrice: "0.10000000", Volume: "8913.30000000", QuoteVolume: "15.30000000", OpenTime: 1499783499040,
+			CloseTime: 1499869899040, FirstID: 28385, LastID: 28460, Count: 76,
+		}
+	}
+	b.Run("allPrices", func(b *testing.B) {
+		benchmarkDecode(b, prices, decodeJSON[[]model.TickerPrice]())
+	})
+	b.Run("all24hrTickers", func(b *testing.B) {
+		benchmarkDecode(b, tickers, decodeJSON[[]model.Ticker24h]())
+	})
+}
+
+func benchmarkDecode(b *testing.B, value interface{}, decode decodeFunc) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := newRawResponse(body).decode(decode); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisScanCount is the SCAN batch size hint used by Keys.
+	redisScanCount = 1000
+	// redisScanTimeout bounds a whole Keys scan, which takes several round trips.
+	redisScanTimeout = 10 * time.Second
+)
+
+// The fields of the hash stored under each key. Times are Unix milliseconds, and the
+// expire time is a field of its own so that freshness checks do not read the value.
+const (
+	redisFieldStoredAt   = "storedAt"
+	redisFieldExpireTime = "expireTime"
+	redisFieldValue      = "value"
+)
+
+// redisCacheService is a CacheBackend shared by all replicas through a Redis-protocol
+// server. Values are stored as JSON in a hash and kept by Redis for their TTL plus the
+// stale grace window. Redis failures are logged and treated as misses, so the cache
+// never fails a request on its own.
+type redisCacheService struct {
+	client       redis.UniversalClient
+	keyPrefix    string
+	timeout      time.Duration
+	staleGrace   time.Duration
+	gzipMinBytes int
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	errors       atomic.Uint64
+}
+
+// NewRedisCacheService creates and returns a CacheBackend stored in Redis through client.
+func NewRedisCacheService(client redis.UniversalClient, cfg config.CacheConfig) CacheBackend {
+	return &redisCacheService{
+		client:       client,
+		keyPrefix:    cfg.Redis.KeyPrefix,
+		timeout:      cfg.Redis.Timeout.Duration(),
+		staleGrace:   cfg.StaleIfErrorGrace.Duration(),
+		gzipMinBytes: gzipMinBytesOf(cfg.Passthrough),
+	}
+}
+
+func (c *redisCacheService) Set(key string, value interface{}, ttl time.Duration) {
+	data, err := encodeCachedValue(value)
+	if err != nil {
+		c.fail("encoding", key, err)
+		return
+	}
+	now := time.Now()
+
+	// Replace the whole hash, and any value of another type left by an older version,
+	// in one transaction.
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, c.keyPrefix+key)
+		pipe.HSet(ctx, c.keyPrefix+key,
+			redisFieldStoredAt, now.UnixMilli(),
+			redisFieldExpireTime, now.Add(ttl).UnixMilli(),
+			redisFieldValue, data)
+		pipe.PExpire(ctx, c.keyPrefix+key, max(ttl+c.staleGrace, time.Millisecond))
+		return nil
+	})
+	if err != nil {
+		c.fail("writing", key, err)
+	}
+}
+
+func (c *redisCacheService) Get(key string) (interface{}, bool) {
+	entry, ok := c.GetEntry(key)
+	if !ok || entry.Expired() {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+func (c *redisCacheService) GetEntry(key string) (*CacheEntry, bool) {
+	entry, ok := c.load(key)
+	if !ok || entry.Expired() {
+		c.misses.Add(1)
+	} else {
+		c.hits.Add(1)
+	}
+	return entry, ok
+}
+
+func (c *redisCacheService) GetExpireTime(key string) (*time.Time, bool) {
+	expireTime, ok := c.loadExpireTime(key)
+	if !ok || time.Now().After(expireTime) {
+		return nil, false
+	}
+	return &expireTime, true
+}
+
+func (c *redisCacheService) Del(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	if err := c.client.Del(ctx, c.keyPrefix+key).Err(); err != nil {
+		c.fail("deleting", key, err)
+	}
+}
+
+// Has reports whether key holds a fresh entry without counting as a lookup.
+func (c *redisCacheService) Has(key string) bool {
+	expireTime, ok := c.loadExpireTime(key)
+	return ok && !time.Now().After(expireTime)
+}
+
+func (c *redisCacheService) Keys(prefix string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), redisScanTimeout)
+	defer cancel()
+	var keys []string
+	iter := c.client.Scan(ctx, 0, escapeGlob(c.keyPrefix+prefix)+"*", redisScanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, strings.TrimPrefix(iter.Val(), c.keyPrefix))
+	}
+	if err := iter.Err(); err != nil {
+		c.fail("scanning", prefix+"*", err)
+	}
+	return keys
+}
+
+func (c *redisCacheService) Stats() CacheStats {
+	return CacheStats{
+		Backend: CacheBackendRedis,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Errors:  c.errors.Load(),
+	}
+}
+
+// load reads the hash stored for key. The value is returned as a RawResponse.
+func (c *redisCacheService) load(key string) (*CacheEntry, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	fields, err := c.client.HMGet(ctx, c.keyPrefix+key, redisFieldStoredAt, redisFieldExpireTime, redisFieldValue).Result()
+	if err != nil {
+		c.fail("reading", key, err)
+		return nil, false
+	}
+	if fields[0] == nil && fields[1] == nil && fields[2] == nil {
+		return nil, false
+	}
+	storedAt, err := parseRedisTime(fields[0])
+	if err != nil {
+		c.fail("decoding", key, err)
+		return nil, false
+	}
+	expireTime, err := parseRedisTime(fields[1])
+	if err != nil {
+		c.fail("decoding", key, err)
+		return nil, false
+	}
+	value, ok := fields[2].(string)
+	if !ok {
+		c.fail("decoding", key, errors.New("missing value"))
+		return nil, false
+	}
+	return &CacheEntry{
+		Value:      newStoredResponse(key, []byte(value), c.gzipMinBytes),
+		StoredAt:   storedAt,
+		ExpireTime: expireTime,
+	}, true
+}
+
+// loadExpireTime reads only the expire time stored for key.
+func (c *redisCacheService) loadExpireTime(key string) (time.Time, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	value, err := c.client.HGet(ctx, c.keyPrefix+key, redisFieldExpireTime).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.fail("reading", key, err)
+		}
+		return time.Time{}, false
+	}
+	expireTime, err := parseRedisTime(value)
+	if err != nil {
+		c.fail("decoding", key, err)
+		return time.Time{}, false
+	}
+	return expireTime, true
+}
+
+// parseRedisTime parses a time field, as returned by HMGET or HGET.
+func parseRedisTime(field interface{}) (time.Time, error) {
+	value, ok := field.(string)
+	if !ok {
+		return time.Time{}, errors.New("missing time field")
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
+
+func (c *redisCacheService) fail(action, key string, err error) {
+	c.errors.Add(1)
+	slog.Warn("Redis cache command failed", "action", action, "key", key, "error", err)
+}
+
+// escapeGlob escapes the characters that are special in a Redis MATCH pattern.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
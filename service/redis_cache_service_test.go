@@ -0,0 +1,202 @@
+package service
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/ntdat104/go-crypto/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedisCache returns a Redis cache backed by a miniredis server, with keyPrefix
+// and a one second stale grace.
+func newTestRedisCache(t *testing.T, keyPrefix string) (*miniredis.Miniredis, config.CacheConfig, CacheBackend) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	cfg := config.Default().Cache
+	cfg.Redis.Addr = server.Addr()
+	cfg.Redis.KeyPrefix = keyPrefix
+	cfg.StaleIfErrorGrace = config.Duration(time.Second)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, cfg, NewRedisCacheService(client, cfg)
+}
+
+func TestRedisCacheSetGetEntry(t *testing.T) {
+	server, _, cache := newTestRedisCache(t, "test:")
+	body := `{"symbol":"BTCUSDT","price":"65000.00"}`
+	cache.Set("spot_tickerprice:BTCUSDT", newRawResponse([]byte(body)), 50*time.Millisecond)
+
+	entry, ok := cache.GetEntry("spot_tickerprice:BTCUSDT")
+	if !ok || entry.Expired() {
+		t.Fatalf("GetEntry = %+v, %v, want a fresh entry", entry, ok)
+	}
+	raw, ok := entry.Value.(*RawResponse)
+	if !ok || string(raw.Body()) != body {
+		t.Fatalf("value = %#v, want the stored body", entry.Value)
+	}
+	if ttl := server.TTL("test:spot_tickerprice:BTCUSDT"); ttl != 50*time.Millisecond+time.Second {
+		t.Fatalf("Redis TTL = %s, want the TTL plus the stale grace", ttl)
+	}
+
+	// Past its TTL the entry is still returned, expired, for stale-if-error serving,
+	// until Redis drops it at the end of the grace window.
+	time.Sleep(60 * time.Millisecond)
+	entry, ok = cache.GetEntry("spot_tickerprice:BTCUSDT")
+	if !ok || !entry.Expired() {
+		t.Fatalf("GetEntry within the grace = %+v, %v, want an expired entry", entry, ok)
+	}
+	if _, ok := cache.Get("spot_tickerprice:BTCUSDT"); ok {
+		t.Fatal("Get returned an expired entry")
+	}
+	// miniredis only expires keys when told that time has passed.
+	server.FastForward(50*time.Millisecond + time.Second)
+	if _, ok := cache.GetEntry("spot_tickerprice:BTCUSDT"); ok {
+		t.Fatal("GetEntry returned an entry past the grace")
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 3 || stats.Errors != 0 {
+		t.Fatalf("stats = %+v, want 1 hit, 3 misses and no errors", stats)
+	}
+}
+
+func TestRedisCacheFreshnessReadsOnlyExpireTime(t *testing.T) {
+	server, _, cache := newTestRedisCache(t, "test:")
+	cache.Set("spot_tickerprice:BTCUSDT", newRawResponse([]byte(`{"symbol":"BTCUSDT"}`)), time.Minute)
+
+	// Without the value the entry cannot be served, but its freshness is still known.
+	server.HDel("test:spot_tickerprice:BTCUSDT", redisFieldValue)
+	if !cache.Has("spot_tickerprice:BTCUSDT") {
+		t.Fatal("Has = false for a fresh entry")
+	}
+	if expireTime, ok := cache.GetExpireTime("spot_tickerprice:BTCUSDT"); !ok || time.Until(*expireTime) <= 0 {
+		t.Fatalf("GetExpireTime = %v, %v, want a time in the future", expireTime, ok)
+	}
+	if _, ok := cache.GetEntry("spot_tickerprice:BTCUSDT"); ok {
+		t.Fatal("GetEntry returned an entry without a value")
+	}
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 1 || stats.Errors != 1 {
+		t.Fatalf("stats = %+v, want only the GetEntry counted", stats)
+	}
+}
+
+func TestRedisCacheRestoresGzipCopy(t *testing.T) {
+	server, cfg, _ := newTestRedisCache(t, "test:")
+	cfg.Passthrough = config.PassthroughConfig{Enabled: true, GzipMinBytes: 16}
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	cache := NewRedisCacheService(client, cfg)
+
+	cache.Set("spot_tickerprice:BTCUSDT", newRawResponse([]byte(`{"symbol":"BTCUSDT","price":"65000.00"}`)), time.Minute)
+	cache.Set("spot_ping:", newRawResponse([]byte(`{}`)), time.Minute)
+
+	entry, ok := cache.GetEntry("spot_tickerprice:BTCUSDT")
+	if !ok {
+		t.Fatal("GetEntry missed")
+	}
+	if _, ok := entry.Value.(*RawResponse).Gzipped(); !ok {
+		t.Fatal("a large body was loaded without its gzip copy")
+	}
+	entry, ok = cache.GetEntry("spot_ping:")
+	if !ok {
+		t.Fatal("GetEntry missed")
+	}
+	if _, ok := entry.Value.(*RawResponse).Gzipped(); ok {
+		t.Fatal("a body under gzipMinBytes was compressed")
+	}
+}
+
+func TestRedisCacheKeysEscapesPrefix(t *testing.T) {
+	server, _, cache := newTestRedisCache(t, "env[1]*:")
+	for _, key := range []string{"spot_depth:BTCUSDT-10", "spot_depth:ETHUSDT-10", "spot_klines:BTCUSDT-1m"} {
+		cache.Set(key, newRawResponse([]byte(`{}`)), time.Minute)
+	}
+	// Both keys match the prefixes as globs, but not literally.
+	server.Set("env1x:spot_depth:BTCUSDT-10", "{}")
+	server.Set("env[1]*:spot_depthX", "{}")
+
+	keys := cache.Keys("spot_depth:")
+	slices.Sort(keys)
+	if want := []string{"spot_depth:BTCUSDT-10", "spot_depth:ETHUSDT-10"}; !slices.Equal(keys, want) {
+		t.Fatalf("Keys = %v, want %v", keys, want)
+	}
+}
+
+func TestEscapeGlob(t *testing.T) {
+	if got, want := escapeGlob(`a*b?c[d]e\f`), `a\*b\?c\[d\]e\\f`; got != want {
+		t.Fatalf("escapeGlob = %q, want %q", got, want)
+	}
+}
+
+func TestTieredCachePromotesFromRedis(t *testing.T) {
+	server, cfg, shared := newTestRedisCache(t, "test:")
+	cache := NewTieredCacheService(cfg, shared).(*tieredCacheService)
+
+	// Another replica stored the entry, so only Redis has it.
+	shared.Set("spot_tickerprice:BTCUSDT", newRawResponse([]byte(`{"symbol":"BTCUSDT"}`)), time.Minute)
+	if cache.l1.Has("spot_tickerprice:BTCUSDT") {
+		t.Fatal("L1 has an entry it never stored")
+	}
+	stored, ok := shared.GetEntry("spot_tickerprice:BTCUSDT")
+	if !ok {
+		t.Fatal("Redis lost the entry")
+	}
+
+	entry, ok := cache.GetEntry("spot_tickerprice:BTCUSDT")
+	if !ok || entry.Expired() {
+		t.Fatalf("GetEntry = %+v, %v, want the entry from Redis", entry, ok)
+	}
+	local, ok := cache.l1.GetEntry("spot_tickerprice:BTCUSDT")
+	if !ok {
+		t.Fatal("the entry from Redis was not copied into L1")
+	}
+	if !local.StoredAt.Equal(stored.StoredAt) || !local.ExpireTime.Equal(stored.ExpireTime) {
+		t.Fatalf("L1 times = %s/%s, want the Redis times %s/%s", local.StoredAt, local.ExpireTime, stored.StoredAt, stored.ExpireTime)
+	}
+
+	// Later reads are served by L1 alone.
+	server.FlushAll()
+	if _, ok := cache.Get("spot_tickerprice:BTCUSDT"); !ok {
+		t.Fatal("L1 did not serve the promoted entry")
+	}
+}
+
+func TestRedisCacheUnreachable(t *testing.T) {
+	// Take a free address and close it, so that every connection is refused.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	cfg := config.Default().Cache
+	cfg.Redis.Addr = addr
+	cfg.Redis.Timeout = config.Duration(50 * time.Millisecond)
+	client := redis.NewClient(&redis.Options{Addr: addr, DialTimeout: 50 * time.Millisecond, MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	shared := NewRedisCacheService(client, cfg)
+
+	shared.Set("spot_tickerprice:BTCUSDT", newRawResponse([]byte(`{}`)), time.Minute)
+	if _, ok := shared.GetEntry("spot_tickerprice:BTCUSDT"); ok {
+		t.Fatal("GetEntry returned an entry from an unreachable server")
+	}
+	if keys := shared.Keys("spot_"); len(keys) != 0 {
+		t.Fatalf("Keys = %v, want none", keys)
+	}
+	shared.Del("spot_tickerprice:BTCUSDT")
+	if stats := shared.Stats(); stats.Errors != 4 || stats.Misses != 1 {
+		t.Fatalf("stats = %+v, want 4 errors and 1 miss", stats)
+	}
+
+	// The tiered cache keeps serving what this replica stored.
+	cache := NewTieredCacheService(cfg, shared)
+	cache.Set("spot_tickerprice:BTCUSDT", newRawResponse([]byte(`{}`)), time.Minute)
+	if _, ok := cache.Get("spot_tickerprice:BTCUSDT"); !ok {
+		t.Fatal("the tiered cache did not serve its L1 entry")
+	}
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// RefreshScheduler keeps hot keys fresh in the background instead of on the reads that
+// find them due. The warm list is fetched at startup and scheduled; other keys are
+// scheduled once they are read often enough, and every scheduled key is demoted again
+// when nobody reads it. Refreshes are skipped while the upstream has used more than
+// its share of the rate-limit budget.
+type RefreshScheduler interface {
+	// Warm fetches the warm list and schedules its keys.
+	Warm(ctx context.Context, spot BinanceSpotService, futures BinanceFuturesService)
+	// Run refreshes the scheduled keys that are due until ctx is done.
+	Run(ctx context.Context)
+	Stats() RefreshSchedulerStats
+
+	// observe records a read of key and reports whether key is scheduled, in which case
+	// the caller leaves refreshing it to the scheduler.
+	observe(ctx context.Context, upstream, key, delayKey string, refresh func(ctx context.Context)) bool
+}
+
+// RefreshSchedulerStats reports the state of the refresh scheduler.
+type RefreshSchedulerStats struct {
+	// Warmed is set once every key of the warm list has been fetched or has failed.
+	Warmed bool `json:"warmed"`
+	// WarmFailures is the number of warm list keys that could not be fetched at startup.
+	WarmFailures int `json:"warmFailures"`
+	// Scheduled is the number of keys currently kept fresh.
+	Scheduled int `json:"scheduled"`
+	// Refreshes is the number of refreshes started by the scheduler.
+	Refreshes uint64 `json:"refreshes"`
+	// SkippedBudget is the number of due refreshes skipped to protect the rate-limit budget.
+	SkippedBudget uint64 `json:"skippedBudget"`
+	// Promotions and Demotions count keys added to and removed from the schedule.
+	Promotions uint64 `json:"promotions"`
+	Demotions  uint64 `json:"demotions"`
+}
+
+type warmRequestKey struct{}
+
+type scheduledKey struct {
+	upstream string
+	delayKey string
+	refresh  func(ctx context.Context)
+	lastRead time.Time
+}
+
+// promotionCandidate counts the reads of an unscheduled key in the current window.
+type promotionCandidate struct {
+	reads       int
+	windowStart time.Time
+}
+
+type refreshScheduler struct {
+	cfg           config.WarmConfig
+	cache         CacheBackend
+	refresher     BackgroundRefresher
+	governor      RateLimitGovernor
+	maxCandidates int
+
+	mu           sync.Mutex
+	scheduled    map[string]*scheduledKey
+	candidates   map[string]*promotionCandidate
+	warmed       bool
+	warmFailures int
+
+	refreshes     atomic.Uint64
+	skippedBudget atomic.Uint64
+	promotions    atomic.Uint64
+	demotions     atomic.Uint64
+}
+
+// NewRefreshScheduler creates and returns a new RefreshScheduler. At most maxCandidates
+// unscheduled keys are watched for promotion at a time.
+func NewRefreshScheduler(cfg config.WarmConfig, cache CacheBackend, refresher BackgroundRefresher, governor RateLimitGovernor, maxCandidates int) RefreshScheduler {
+	return &refreshScheduler{
+		cfg:           cfg,
+		cache:         cache,
+		refresher:     refresher,
+		governor:      governor,
+		maxCandidates: maxCandidates,
+		scheduled:     make(map[string]*scheduledKey),
+		candidates:    make(map[string]*promotionCandidate),
+	}
+}
+
+func (s *refreshScheduler) Warm(ctx context.Context, spot BinanceSpotService, futures BinanceFuturesService) {
+	warmCtx := context.WithValue(ctx, warmRequestKey{}, true)
+	var wg sync.WaitGroup
+	var failures atomic.Int32
+	for _, key := range s.cfg.Keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fetchWarmKey(warmCtx, key, spot, futures); err != nil {
+				failures.Add(1)
+				slog.WarnContext(ctx, "Failed to warm cache key", "upstream", key.Upstream, "endpoint", key.Endpoint, "symbol", key.Symbol, "interval", key.Interval, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warmed = true
+	s.warmFailures = int(failures.Load())
+	slog.InfoContext(ctx, "Warmed cache", "warmed", len(s.cfg.Keys)-s.warmFailures, "keys", len(s.cfg.Keys))
+}
+
+func (s *refreshScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Tick.Duration())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.tick(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *refreshScheduler) Stats() RefreshSchedulerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return RefreshSchedulerStats{
+		Warmed:        s.warmed,
+		WarmFailures:  s.warmFailures,
+		Scheduled:     len(s.scheduled),
+		Refreshes:     s.refreshes.Load(),
+		SkippedBudget: s.skippedBudget.Load(),
+		Promotions:    s.promotions.Load(),
+		Demotions:     s.demotions.Load(),
+	}
+}
+
+func (s *refreshScheduler) observe(ctx context.Context, upstream, key, delayKey string, refresh func(ctx context.Context)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if scheduled, ok := s.scheduled[key]; ok {
+		scheduled.lastRead = now
+		return true
+	}
+
+	warm, _ := ctx.Value(warmRequestKey{}).(bool)
+	if !warm {
+		if s.cfg.PromoteReads <= 0 {
+			return false
+		}
+		candidate, ok := s.candidates[key]
+		if !ok || now.Sub(candidate.windowStart) > s.cfg.IdleTimeout.Duration() {
+			if !ok && len(s.candidates) >= s.maxCandidates {
+				return false
+			}
+			candidate = &promotionCandidate{windowStart: now}
+			s.candidates[key] = candidate
+		}
+		candidate.reads++
+		if candidate.reads < s.cfg.PromoteReads {
+			return false
+		}
+		delete(s.candidates, key)
+	}
+	s.scheduled[key] = &scheduledKey{upstream: upstream, delayKey: delayKey, refresh: refresh, lastRead: now}
+	s.promotions.Add(1)
+	// Until its first scheduled refresh, the read that promoted the key keeps refreshing it.
+	return false
+}
+
+// tick demotes idle keys, drops stale promotion windows and refreshes the keys whose
+// refresh interval has passed.
+func (s *refreshScheduler) tick(ctx context.Context) {
+	now := time.Now()
+	idleTimeout := s.cfg.IdleTimeout.Duration()
+
+	s.mu.Lock()
+	due := make(map[string]*scheduledKey)
+	for key, scheduled := range s.scheduled {
+		if now.Sub(scheduled.lastRead) > idleTimeout {
+			delete(s.scheduled, key)
+			s.demotions.Add(1)
+			continue
+		}
+		due[key] = scheduled
+	}
+	for key, candidate := range s.candidates {
+		if now.Sub(candidate.windowStart) > idleTimeout {
+			delete(s.candidates, key)
+		}
+	}
+	s.mu.Unlock()
+
+	budgets := s.governor.Stats()
+	for key, scheduled := range due {
+		// The delay key is set by every fetch and expires after the refresh interval.
+		if s.cache.Has(scheduled.delayKey) {
+			continue
+		}
+		if budget, ok := budgets[scheduled.upstream]; ok && float64(budget.UsedWeight) >= s.cfg.BudgetShare*float64(budget.Budget) {
+			s.skippedBudget.Add(1)
+			continue
+		}
+		refresh := scheduled.refresh
+		if s.refresher.Trigger(key, func() { refresh(ctx) }) {
+			s.refreshes.Add(1)
+		}
+	}
+}
+
+// Limits used for warm keys without one, matching the defaults of the API routes so that
+// warmed keys are the ones clients read.
+const (
+	defaultWarmDepthLimit        = 10
+	defaultWarmSpotKlinesLimit   = 10
+	defaultWarmFutureKlinesLimit = 500
+	defaultWarmFundingRateLimit  = 100
+)
+
+// fetchWarmKey reads the warm list key through the service, which caches it and, with a
+// warm request context, schedules it.
+func fetchWarmKey(ctx context.Context, key config.WarmKey, spot BinanceSpotService, futures BinanceFuturesService) error {
+	limit := func(def int) int {
+		if key.Limit > 0 {
+			return key.Limit
+		}
+		return def
+	}
+	var err error
+	switch key.Upstream + "/" + key.Endpoint {
+	case "spot/exchangeInfo":
+		_, err = spot.GetExchangeInfo(ctx)
+	case "spot/allPrices":
+		_, err = spot.GetAllTickerPrices(ctx)
+	case "spot/allBookTickers":
+		_, err = spot.GetAllBookTickers(ctx)
+	case "spot/tickerPrice":
+		_, err = spot.GetTickerPrice(ctx, key.Symbol)
+	case "spot/bookTicker":
+		_, err = spot.GetBookTicker(ctx, key.Symbol)
+	case "spot/ticker24hr":
+		_, err = spot.GetTicker24Hr(ctx, key.Symbol)
+	case "spot/depth":
+		_, err = spot.GetDepth(ctx, key.Symbol, limit(defaultWarmDepthLimit))
+	case "spot/klines":
+		_, err = spot.GetKlines(ctx, key.Symbol, key.Interval, limit(defaultWarmSpotKlinesLimit))
+	case "futures/exchangeInfo":
+		_, err = futures.GetExchangeInfo(ctx)
+	case "futures/allPrices":
+		_, err = futures.GetAllTickerPrices(ctx)
+	case "futures/all24hrTickers":
+		_, err = futures.GetAll24HrTickers(ctx)
+	case "futures/tickerPrice":
+		_, err = futures.GetTickerPrice(ctx, key.Symbol)
+	case "futures/bookTicker":
+		_, err = futures.GetBookTicker(ctx, key.Symbol)
+	case "futures/ticker24hr":
+		_, err = futures.Get24HrTicker(ctx, key.Symbol)
+	case "futures/depth":
+		_, err = futures.GetDepth(ctx, key.Symbol, limit(defaultWarmDepthLimit))
+	case "futures/klines":
+		_, err = futures.GetKlines(ctx, key.Symbol, key.Interval, limit(defaultWarmFutureKlinesLimit))
+	case "futures/markPrice":
+		_, err = futures.GetMarkPrice(ctx, key.Symbol)
+	case "futures/fundingRate":
+		_, err = futures.GetFundingRate(ctx, key.Symbol, nil, nil, limit(defaultWarmFundingRateLimit))
+	default:
+		err = fmt.Errorf("unsupported warm endpoint %s/%s", key.Upstream, key.Endpoint)
+	}
+	return err
+}
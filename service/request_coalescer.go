@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// RequestCoalescer lets concurrent callers asking for the same key share a single
+// in-flight call. Every waiter receives the result or error of that one call.
+type RequestCoalescer interface {
+	// Do runs fn once for all concurrent callers with the same key. A caller whose ctx is
+	// cancelled stops waiting; the shared call is only cancelled once every caller has left.
+	Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error)
+	Stats() CoalescerStats
+}
+
+// CoalescerStats reports how many upstream calls were made and how many were saved.
+type CoalescerStats struct {
+	// Calls is the number of times a function was actually executed.
+	Calls uint64 `json:"calls"`
+	// Coalesced is the number of callers that waited on an in-flight call instead of
+	// making their own, i.e. the number of upstream calls saved.
+	Coalesced uint64 `json:"coalesced"`
+	// InFlight is the number of calls currently running.
+	InFlight int `json:"inFlight"`
+}
+
+type flight struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+type requestCoalescer struct {
+	mu        sync.Mutex
+	flights   map[string]*flight
+	calls     atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+// NewRequestCoalescer creates and returns a new RequestCoalescer instance.
+func NewRequestCoalescer() RequestCoalescer {
+	return &requestCoalescer{
+		flights: make(map[string]*flight),
+	}
+}
+
+// Do runs fn once for all concurrent callers with the same key.
+func (c *requestCoalescer) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	f, ok := c.flights[key]
+	if ok {
+		f.waiters++
+		c.mu.Unlock()
+		c.coalesced.Add(1)
+	} else {
+		// The shared call must not die with the first caller, so it keeps ctx's values
+		// but gets its own cancellation.
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		c.flights[key] = f
+		c.mu.Unlock()
+		c.calls.Add(1)
+		go c.run(flightCtx, key, f, fn)
+	}
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		c.leave(key, f)
+		return nil, ctx.Err()
+	}
+}
+
+// run executes the shared call and releases every waiter.
+func (c *requestCoalescer) run(ctx context.Context, key string, f *flight, fn func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			f.value, f.err = nil, fmt.Errorf("coalesced call for %s panicked: %v", key, r)
+		}
+		c.mu.Lock()
+		if c.flights[key] == f {
+			delete(c.flights, key)
+		}
+		c.mu.Unlock()
+		f.cancel()
+		close(f.done)
+	}()
+	f.value, f.err = fn(ctx)
+}
+
+// leave removes a cancelled waiter and cancels the shared call once nobody is waiting.
+func (c *requestCoalescer) leave(key string, f *flight) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+	f.cancel()
+}
+
+// Stats returns a snapshot of the coalescer counters.
+func (c *requestCoalescer) Stats() CoalescerStats {
+	c.mu.Lock()
+	inFlight := len(c.flights)
+	c.mu.Unlock()
+	return CoalescerStats{
+		Calls:     c.calls.Load(),
+		Coalesced: c.coalesced.Load(),
+		InFlight:  inFlight,
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// retryPolicy retries transient upstream failures with jittered exponential backoff.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+func newRetryPolicy(cfg config.RetryConfig) retryPolicy {
+	return retryPolicy{
+		maxAttempts: cfg.MaxAttempts,
+		baseDelay:   cfg.BaseDelay.Duration(),
+		maxDelay:    cfg.MaxDelay.Duration(),
+	}
+}
+
+// backoff returns the wait before the given retry (1 for the first retry). It uses
+// "full jitter": a random duration up to the capped exponential delay.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	ceiling := p.baseDelay << (retry - 1)
+	if ceiling <= 0 || ceiling > p.maxDelay {
+		ceiling = p.maxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// sleep waits for d or until ctx is done, reporting whether the full wait elapsed.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// isTransient reports whether err is a failure worth retrying: a transport error such as
+// a connection reset or an attempt timeout, or a 5xx response.
+func isTransient(err error) bool {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode >= http.StatusInternalServerError
+	}
+	return errors.Is(err, ErrUpstreamUnreachable)
+}
+
+// breakerOutcomeOf classifies the result of one attempt for the circuit breaker. Failures
+// caused by the caller giving up, rate limiting or bad requests say nothing about the
+// upstream's health and are ignored.
+func breakerOutcomeOf(ctx context.Context, err error) breakerOutcome {
+	switch {
+	case err == nil:
+		return outcomeSuccess
+	case ctx.Err() != nil:
+		return outcomeIgnored
+	case isTransient(err), errors.Is(err, ErrInvalidUpstreamResponse):
+		return outcomeFailure
+	default:
+		return outcomeIgnored
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// tieredCacheService keeps an in-process L1 in front of a shared L2. Writes go to both
+// tiers; reads are served from L1 while it holds a fresh entry and otherwise from L2,
+// whose entry is then copied into L1 with its original stored and expire times.
+type tieredCacheService struct {
+	l1 *localCacheService
+	l2 CacheBackend
+}
+
+// NewTieredCacheService creates and returns a CacheBackend with an in-memory L1 built
+// from cfg over l2.
+func NewTieredCacheService(cfg config.CacheConfig, l2 CacheBackend) CacheBackend {
+	return &tieredCacheService{
+		l1: newLocalCacheService(cfg),
+		l2: l2,
+	}
+}
+
+func (c *tieredCacheService) Set(key string, value interface{}, ttl time.Duration) {
+	c.l1.Set(key, value, ttl)
+	c.l2.Set(key, value, ttl)
+}
+
+func (c *tieredCacheService) Get(key string) (interface{}, bool) {
+	entry, ok := c.GetEntry(key)
+	if !ok || entry.Expired() {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+func (c *tieredCacheService) GetEntry(key string) (*CacheEntry, bool) {
+	local, ok := c.l1.GetEntry(key)
+	if ok && !local.Expired() {
+		return local, true
+	}
+	shared, found := c.l2.GetEntry(key)
+	if !found {
+		return local, ok
+	}
+	if ok && !shared.StoredAt.After(local.StoredAt) {
+		// Another replica has not stored anything newer than what L1 holds.
+		return local, true
+	}
+	c.l1.setEntry(key, shared)
+	return shared, true
+}
+
+func (c *tieredCacheService) GetExpireTime(key string) (*time.Time, bool) {
+	if expireTime, ok := c.l1.GetExpireTime(key); ok {
+		return expireTime, true
+	}
+	return c.l2.GetExpireTime(key)
+}
+
+func (c *tieredCacheService) Del(key string) {
+	c.l1.Del(key)
+	c.l2.Del(key)
+}
+
+func (c *tieredCacheService) Has(key string) bool {
+	return c.l1.Has(key) || c.l2.Has(key)
+}
+
+// Keys returns the keys of both tiers. L1 may hold keys that have already left L2.
+func (c *tieredCacheService) Keys(prefix string) []string {
+	keys := c.l2.Keys(prefix)
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		seen[key] = struct{}{}
+	}
+	for _, key := range c.l1.Keys(prefix) {
+		if _, ok := seen[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Stats counts a lookup as a hit when either tier served a fresh entry.
+func (c *tieredCacheService) Stats() CacheStats {
+	l1, l2 := c.l1.Stats(), c.l2.Stats()
+	return CacheStats{
+		Backend:         CacheBackendTiered,
+		Hits:            l1.Hits + l2.Hits,
+		Misses:          l2.Misses,
+		Evictions:       l1.Evictions,
+		EvictionsByName: l1.EvictionsByName,
+		Errors:          l2.Errors,
+		Tiers:           []CacheStats{l1, l2},
+	}
+}
@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UpstreamDependencies bundles the collaborators shared by the spot and futures services.
+type UpstreamDependencies struct {
+	Cache      CacheBackend
+	Coalescer  RequestCoalescer
+	Refresher  BackgroundRefresher
+	HTTPClient *http.Client
+	Governor   RateLimitGovernor
+	Breakers   CircuitBreakerRegistry
+	Admin      CacheAdmin
+	Scheduler  RefreshScheduler
+	// Passthrough decides whether fetched bodies get a gzip copy for pass-through responses.
+	Passthrough config.PassthroughConfig
+	// Containment configures answering depth and klines lookups from larger responses.
+	Containment config.ContainmentConfig
+	Clock       UpstreamClock
+	Monitor     UpstreamMonitor
+	Metrics     Metrics
+}
+
+// upstream holds the cache and HTTP plumbing shared by the spot and futures services.
+type upstream struct {
+	name             string
+	cache            CacheBackend
+	coalescer        RequestCoalescer
+	refresher        BackgroundRefresher
+	httpClient       *http.Client
+	governor         RateLimitGovernor
+	breaker          *circuitBreaker
+	admin            CacheAdmin
+	scheduler        RefreshScheduler
+	clock            UpstreamClock
+	monitor          UpstreamMonitor
+	metrics          Metrics
+	retry            retryPolicy
+	weight           weightFunc
+	policies         cachePolicies
+	requestTimeout   time.Duration
+	endpointTimeouts map[string]time.Duration
+	gzipMinBytes     int
+	// passthrough leaves decoding to typed readers, as bodies may be served as they are.
+	passthrough bool
+	limits      *limitIndex
+}
+
+// cacheRequest describes one cached upstream lookup.
+type cacheRequest struct {
+	cacheName string
+	key       string
+	delayKey  string
+	apiURL    string
+	params    map[string]string
+	policy    cachePolicy
+	// timing, if set, receives the timing of the last attempt.
+	timing *requestTiming
+	// decode turns the body into the model of the lookup.
+	decode decodeFunc
+}
+
+func newUpstream(name string, weight weightFunc, deps UpstreamDependencies, cfg config.UpstreamConfig) *upstream {
+	deps.Governor.Register(name, cfg.RateLimit)
+	deps.Breakers.Register(name, cfg.CircuitBreaker)
+	endpointTimeouts := make(map[string]time.Duration, len(cfg.EndpointTimeouts))
+	for cacheName, timeout := range cfg.EndpointTimeouts {
+		endpointTimeouts[cacheName] = timeout.Duration()
+	}
+	var limits *limitIndex
+	if deps.Containment.Enabled {
+		limits = newLimitIndex(deps.Containment)
+	}
+	return &upstream{
+		name:             name,
+		cache:            deps.Cache,
+		coalescer:        deps.Coalescer,
+		refresher:        deps.Refresher,
+		httpClient:       deps.HTTPClient,
+		governor:         deps.Governor,
+		breaker:          deps.Breakers.breaker(name),
+		retry:            newRetryPolicy(cfg.Retry),
+		admin:            deps.Admin,
+		scheduler:        deps.Scheduler,
+		clock:            deps.Clock,
+		monitor:          deps.Monitor,
+		metrics:          deps.Metrics,
+		weight:           weight,
+		policies:         newCachePolicies(cfg),
+		requestTimeout:   cfg.RequestTimeout.Duration(),
+		endpointTimeouts: endpointTimeouts,
+		gzipMinBytes:     gzipMinBytesOf(deps.Passthrough),
+		passthrough:      deps.Passthrough.Enabled,
+		limits:           limits,
+	}
+}
+
+// cacheKey returns the cache key of a lookup.
+func (u *upstream) cacheKey(cacheName, keySuffix string) string {
+	return fmt.Sprintf("%s_%s:%s", u.name, cacheName, keySuffix)
+}
+
+// timeout returns the upstream deadline for the endpoint behind cacheName.
+func (u *upstream) timeout(cacheName string) time.Duration {
+	if timeout, ok := u.endpointTimeouts[cacheName]; ok {
+		return timeout
+	}
+	return u.requestTimeout
+}
+
+// fetchData makes an HTTP GET request to the given API URL with parameters. Transient
+// failures are retried with backoff, and no call is made while the circuit breaker is open.
+// The body is only checked to be JSON; decoding it is left to the typed callers.
+func (u *upstream) fetchData(ctx context.Context, req cacheRequest) (response *RawResponse, err error) {
+	ctx, span := tracer.Start(ctx, "fetchData", trace.WithAttributes(
+		attribute.String("upstream", u.name),
+		attribute.String("cache.name", req.cacheName),
+	))
+	defer func() { endSpan(span, err) }()
+
+	parsed, err := url.Parse(req.apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing URL: %w", err)
+	}
+	q := parsed.Query()
+	for key, value := range req.params {
+		q.Set(key, value)
+	}
+	parsed.RawQuery = q.Encode()
+	target := parsed.String()
+	span.SetAttributes(attribute.String("url.full", target))
+
+	if req.timing == nil {
+		req.timing = &requestTiming{}
+	}
+	var body []byte
+	var decoded interface{}
+	for attempt := 1; ; attempt++ {
+		span.SetAttributes(attribute.Int("upstream.attempts", attempt))
+		if err = u.breaker.allow(); err != nil {
+			return nil, err
+		}
+		body, err = u.fetchOnce(ctx, req, target)
+		if err == nil {
+			decoded, err = u.checkBody(req, target, body)
+		}
+		outcome := breakerOutcomeOf(ctx, err)
+		u.breaker.record(outcome)
+		u.monitor.record(u.name, req.timing.latency(), err, outcome)
+		if err == nil {
+			break
+		}
+		if attempt >= u.retry.maxAttempts || !isTransient(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		u.breaker.recordRetry()
+		if !sleep(ctx, u.retry.backoff(attempt)) {
+			return nil, fmt.Errorf("retrying %s: %w", target, ctx.Err())
+		}
+	}
+
+	response = newRawResponse(body)
+	if decoded != nil {
+		response = newDecodedResponse(body, decoded)
+	}
+	if err := response.compressIfLarge(u.gzipMinBytes); err != nil {
+		slog.WarnContext(ctx, "Failed to compress response", "upstream", u.name, "url", target, "error", err)
+	}
+	return response, nil
+}
+
+// checkBody makes sure that a fetched body can be served before it is cached, so that a
+// bad one never replaces a good entry. Without pass-through it is decoded, and the
+// decoded value returned; with pass-through it is only checked to be JSON and typed
+// readers decode it on first use.
+func (u *upstream) checkBody(req cacheRequest, target string, body []byte) (interface{}, error) {
+	if u.passthrough || req.decode == nil {
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("error decoding response from %s: %w: body is not valid JSON", target, ErrInvalidUpstreamResponse)
+		}
+		return nil, nil
+	}
+	decoded, err := req.decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding response from %s: %w: %w", target, ErrInvalidUpstreamResponse, err)
+	}
+	return decoded, nil
+}
+
+// fetchOnce makes a single attempt at target and returns the body of a 200 response.
+func (u *upstream) fetchOnce(ctx context.Context, req cacheRequest, target string) (body []byte, err error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout(req.cacheName))
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for %s: %w", target, err)
+	}
+	ctx, span := tracer.Start(ctx, http.MethodGet+" "+httpReq.URL.Path, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("http.request.method", http.MethodGet),
+		attribute.String("server.address", httpReq.URL.Host),
+		attribute.String("url.path", httpReq.URL.Path),
+	))
+	defer func() { endSpan(span, err) }()
+
+	acquireStart := time.Now()
+	if err := u.governor.Acquire(ctx, u.name, u.weight(req.cacheName, req.params)); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int64("ratelimit.wait_ms", time.Since(acquireStart).Milliseconds()))
+	sent := time.Now()
+	resp, err := u.httpClient.Do(httpReq)
+	received := time.Now()
+	if req.timing != nil {
+		req.timing.sent, req.timing.received = sent, received
+	}
+	if err != nil {
+		u.metrics.observeUpstreamCall(u.name, httpReq.URL.Path, 0, received.Sub(sent))
+		return nil, fmt.Errorf("error fetching data from %s: %w: %w", target, ErrUpstreamUnreachable, err)
+	}
+	defer resp.Body.Close()
+	u.metrics.observeUpstreamCall(u.name, httpReq.URL.Path, resp.StatusCode, received.Sub(sent))
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	u.governor.Record(u.name, resp.StatusCode, resp.Header, parseRetryAfter(resp.Header))
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from %s: %w: %w", target, ErrInvalidUpstreamResponse, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamError(u.name, target, resp, body)
+	}
+	return body, nil
+}
+
+// fetchAndCache fetches data from the API and stores it in the cache.
+func (u *upstream) fetchAndCache(ctx context.Context, req cacheRequest) (interface{}, error) {
+	data, err := u.fetchData(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	u.cache.Set(req.key, data, req.policy.ttl)
+	u.cache.Set(req.delayKey, true, req.policy.refreshInterval)
+	return data, nil
+}
+
+// refreshCache refreshes the cache for a given key. It runs on the background refresher,
+// which guarantees that only one refresh per key is in progress.
+func (u *upstream) refreshCache(ctx context.Context, req cacheRequest) {
+	// A refresh outlives the request that triggered it, so it gets its own trace
+	// linked to the request's.
+	ctx, span := tracer.Start(ctx, "refreshCache", trace.WithNewRoot(), trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(append(u.spanAttributes(req), paramAttributes(req.params)...)...))
+	var err error
+	defer func() { endSpan(span, err) }()
+
+	u.cache.Set(req.delayKey, true, req.policy.refreshInterval)
+
+	data, err := u.fetchData(ctx, req)
+	u.metrics.observeRefresh(cacheNameOf(req.key), err)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to refresh cache", "upstream", u.name, "key", req.key, "error", err)
+		u.cache.Del(req.delayKey)
+		return
+	}
+
+	u.cache.Set(req.key, data, req.policy.ttl)
+}
+
+// getWithCache retrieves data from cache or fetches it from the API, caching the result
+// according to the policy of cacheName. Concurrent misses for the same key share one fetch.
+// The result is decoded with decode, except for pass-through requests, which get the
+// RawResponse.
+func (u *upstream) getWithCache(ctx context.Context, cacheName, keySuffix, apiURL string, params map[string]string, decode decodeFunc) (value interface{}, err error) {
+	req := cacheRequest{
+		decode:    decode,
+		cacheName: cacheName,
+		key:       u.cacheKey(cacheName, keySuffix),
+		delayKey:  u.cacheKey(cacheName, keySuffix) + delayKeySuffix,
+		apiURL:    apiURL,
+		params:    params,
+		policy:    u.policies.get(cacheName),
+	}
+	ctx, span := tracer.Start(ctx, "getWithCache", trace.WithAttributes(append(u.spanAttributes(req), paramAttributes(params)...)...))
+	defer func() { endSpan(span, err) }()
+	u.admin.track(req.key, func(ctx context.Context) error {
+		_, err := u.coalescer.Do(ctx, req.key, func(ctx context.Context) (interface{}, error) {
+			return u.fetchAndCache(ctx, req)
+		})
+		return err
+	})
+
+	scheduled := u.scheduler.observe(ctx, u.name, req.key, req.delayKey, func(ctx context.Context) {
+		u.refreshCache(ctx, req)
+	})
+
+	entry, found := u.cache.GetEntry(req.key)
+	if found && !entry.Expired() {
+		if req.policy.staleWhileRevalidate && !scheduled && !u.cache.Has(req.delayKey) {
+			// The refresh outlives the request, so it keeps the request's values but not its cancellation.
+			refreshCtx := context.WithoutCancel(ctx)
+			u.refresher.Trigger(req.key, func() {
+				u.refreshCache(refreshCtx, req)
+			})
+		}
+		u.recordResult(ctx, req, CacheHit, entry)
+		return u.resolve(ctx, req, entry.Value)
+	}
+
+	data, err := u.coalescer.Do(ctx, req.key, func(ctx context.Context) (interface{}, error) {
+		return u.fetchAndCache(ctx, req)
+	})
+	if err != nil {
+		if found && ctx.Err() == nil && canServeStale(err) {
+			slog.WarnContext(ctx, "Serving stale cache after upstream error", "upstream", u.name, "key", req.key, "error", err)
+			u.recordResult(ctx, req, CacheStale, entry)
+			return u.resolve(ctx, req, entry.Value)
+		}
+		u.admin.record(u.name+"_"+req.cacheName, cacheErrorStatus)
+		return nil, err
+	}
+	now := time.Now()
+	u.recordResult(ctx, req, CacheMiss, &CacheEntry{Value: data, StoredAt: now, ExpireTime: now.Add(req.policy.ttl)})
+	return u.resolve(ctx, req, data)
+}
+
+// resolve decodes a cached value for the caller of req. Pass-through callers get the
+// RawResponse as it is. A cached body that cannot be decoded is dropped, so that the next
+// lookup fetches it again, and counted as an upstream failure.
+func (u *upstream) resolve(ctx context.Context, req cacheRequest, value interface{}) (interface{}, error) {
+	if _, ok := PassthroughFromContext(ctx); ok {
+		return value, nil
+	}
+	decoded, err := resolveCachedValue(value, req.decode)
+	if err != nil {
+		u.cache.Del(req.key)
+		u.cache.Del(req.delayKey)
+		u.breaker.record(outcomeFailure)
+		u.monitor.record(u.name, 0, err, outcomeFailure)
+		return nil, fmt.Errorf("error decoding %s: %w: %w", req.key, ErrInvalidUpstreamResponse, err)
+	}
+	return decoded, nil
+}
+
+// recordResult reports how a lookup was served on the request and in the per cache name stats.
+func (u *upstream) recordResult(ctx context.Context, req cacheRequest, status string, entry *CacheEntry) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("cache.result", status))
+	recordCacheResult(ctx, status, entry)
+	u.admin.record(u.name+"_"+req.cacheName, status)
+}
+
+// spanAttributes returns the attributes identifying the lookup of req on a span.
+func (u *upstream) spanAttributes(req cacheRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("upstream", u.name),
+		attribute.String("cache.name", req.cacheName),
+		attribute.String("cache.key", req.key),
+	}
+}
+
+// canServeStale reports whether err means the upstream could not serve the request,
+// as opposed to rejecting it, so that an expired cached value is a better answer.
+func canServeStale(err error) bool {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return !upstreamErr.IsClientError()
+	}
+	return true
+}
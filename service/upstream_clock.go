@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+	"github.com/ntdat104/go-crypto/model"
+)
+
+// clockSamples is the number of recent time measurements kept per upstream. The offset
+// is taken from the one with the shortest round trip, whose midpoint is the most
+// accurate estimate of when the exchange read its clock.
+const clockSamples = 8
+
+// UpstreamClock measures the round-trip latency to each upstream and the offset between
+// the local clock and the exchange clock, which signed requests need for recvWindow.
+// Every ping and time call feeds it, and Run keeps the measurements current.
+type UpstreamClock interface {
+	// Run measures the server time of both upstreams at the configured interval until
+	// ctx is done.
+	Run(ctx context.Context, spot BinanceSpotService, futures BinanceFuturesService)
+	// Offset returns exchange time minus local time for upstream, and false until it has
+	// been measured.
+	Offset(upstream string) (time.Duration, bool)
+	// Now returns the current time of the exchange behind upstream, or the local time
+	// until the offset has been measured.
+	Now(upstream string) time.Time
+	Stats() map[string]UpstreamClockStats
+
+	// record adds a measurement. offset is nil for calls that carry no server time.
+	record(upstream string, latency time.Duration, offset *time.Duration)
+	// recordFailure counts a ping or time call that failed.
+	recordFailure(upstream string, err error)
+}
+
+// UpstreamClockStats reports the measurements of one upstream. Durations are in
+// milliseconds.
+type UpstreamClockStats struct {
+	LastLatencyMs float64 `json:"lastLatencyMs"`
+	// AvgLatencyMs is an exponentially weighted average of the round trips.
+	AvgLatencyMs float64 `json:"avgLatencyMs"`
+	// OffsetMs is exchange time minus local time.
+	OffsetMs   float64    `json:"offsetMs"`
+	Measured   bool       `json:"measured"`
+	Samples    uint64     `json:"samples"`
+	Failures   uint64     `json:"failures"`
+	LastSample *time.Time `json:"lastSample,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+type clockSample struct {
+	latency time.Duration
+	offset  time.Duration
+}
+
+type upstreamClockState struct {
+	lastLatency time.Duration
+	avgLatency  time.Duration
+	offsets     []clockSample
+	offset      time.Duration
+	measured    bool
+	samples     uint64
+	failures    uint64
+	lastSample  time.Time
+	lastError   string
+}
+
+type upstreamClock struct {
+	interval time.Duration
+
+	mu        sync.Mutex
+	upstreams map[string]*upstreamClockState
+}
+
+// NewUpstreamClock creates and returns a new UpstreamClock.
+func NewUpstreamClock(cfg config.ClockConfig) UpstreamClock {
+	return &upstreamClock{
+		interval:  cfg.Interval.Duration(),
+		upstreams: make(map[string]*upstreamClockState),
+	}
+}
+
+func (c *upstreamClock) Run(ctx context.Context, spot BinanceSpotService, futures BinanceFuturesService) {
+	measure := func() {
+		// Failures are recorded by the services; the next tick tries again.
+		spot.GetServerTime(ctx)
+		futures.GetTime(ctx)
+	}
+	measure()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			measure()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *upstreamClock) Offset(upstream string) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.upstreams[upstream]
+	if !ok || !state.measured {
+		return 0, false
+	}
+	return state.offset, true
+}
+
+func (c *upstreamClock) Now(upstream string) time.Time {
+	offset, _ := c.Offset(upstream)
+	return time.Now().Add(offset)
+}
+
+func (c *upstreamClock) Stats() map[string]UpstreamClockStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make(map[string]UpstreamClockStats, len(c.upstreams))
+	for name, state := range c.upstreams {
+		s := UpstreamClockStats{
+			LastLatencyMs: milliseconds(state.lastLatency),
+			AvgLatencyMs:  milliseconds(state.avgLatency),
+			OffsetMs:      milliseconds(state.offset),
+			Measured:      state.measured,
+			Samples:       state.samples,
+			Failures:      state.failures,
+			LastError:     state.lastError,
+		}
+		if !state.lastSample.IsZero() {
+			lastSample := state.lastSample
+			s.LastSample = &lastSample
+		}
+		stats[name] = s
+	}
+	return stats
+}
+
+func (c *upstreamClock) record(upstream string, latency time.Duration, offset *time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := c.state(upstream)
+	state.lastLatency = latency
+	if state.samples == 0 {
+		state.avgLatency = latency
+	} else {
+		state.avgLatency += (latency - state.avgLatency) / 5
+	}
+	state.samples++
+	state.lastSample = time.Now()
+	state.lastError = ""
+	if offset == nil {
+		return
+	}
+
+	state.offsets = append(state.offsets, clockSample{latency: latency, offset: *offset})
+	if len(state.offsets) > clockSamples {
+		state.offsets = state.offsets[1:]
+	}
+	best := state.offsets[0]
+	for _, sample := range state.offsets[1:] {
+		if sample.latency < best.latency {
+			best = sample
+		}
+	}
+	state.offset = best.offset
+	state.measured = true
+}
+
+func (c *upstreamClock) recordFailure(upstream string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := c.state(upstream)
+	state.failures++
+	state.lastError = err.Error()
+}
+
+func (c *upstreamClock) state(upstream string) *upstreamClockState {
+	state, ok := c.upstreams[upstream]
+	if !ok {
+		state = &upstreamClockState{}
+		c.upstreams[upstream] = state
+	}
+	return state
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// requestTiming records when the last attempt of an upstream call was sent and when its
+// response headers arrived.
+type requestTiming struct {
+	sent     time.Time
+	received time.Time
+}
+
+func (t *requestTiming) latency() time.Duration {
+	return t.received.Sub(t.sent)
+}
+
+// ping calls the ping endpoint at apiURL and records its round-trip latency. The reply
+// carries the exchange time as estimated from the measured clock offset.
+func (u *upstream) ping(ctx context.Context, apiURL string) (*model.Ping, error) {
+	timing := &requestTiming{}
+	if _, err := u.fetchData(ctx, cacheRequest{cacheName: "ping", apiURL: apiURL, timing: timing}); err != nil {
+		u.clock.recordFailure(u.name, err)
+		return nil, err
+	}
+	u.clock.record(u.name, timing.latency(), nil)
+	return &model.Ping{
+		ServerTime: u.clock.Now(u.name).UnixMilli(),
+		Message:    "success",
+		LatencyMs:  milliseconds(timing.latency()),
+	}, nil
+}
+
+// serverTime calls the time endpoint at apiURL and records its round-trip latency and
+// the clock offset, assuming the exchange read its clock halfway through the round trip.
+func (u *upstream) serverTime(ctx context.Context, apiURL string) (*model.ServerTime, error) {
+	timing := &requestTiming{}
+	raw, err := u.fetchData(ctx, cacheRequest{cacheName: "time", apiURL: apiURL, timing: timing})
+	if err != nil {
+		u.clock.recordFailure(u.name, err)
+		return nil, err
+	}
+	var resp model.ServerTime
+	if err := json.Unmarshal(raw.Body(), &resp); err != nil {
+		err = fmt.Errorf("error decoding server time from %s: %w: %w", apiURL, ErrInvalidUpstreamResponse, err)
+		u.clock.recordFailure(u.name, err)
+		return nil, err
+	}
+	if resp.ServerTime <= 0 {
+		err := fmt.Errorf("error decoding server time from %s: %w: serverTime is missing", apiURL, ErrInvalidUpstreamResponse)
+		u.clock.recordFailure(u.name, err)
+		return nil, err
+	}
+	latency := timing.latency()
+	offset := time.UnixMilli(resp.ServerTime).Sub(timing.sent.Add(latency / 2))
+	u.clock.record(u.name, latency, &offset)
+	return &resp, nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUpstreamUnreachable wraps transport failures: DNS, connection and TLS errors, timeouts.
+	ErrUpstreamUnreachable = errors.New("upstream unreachable")
+	// ErrInvalidUpstreamResponse wraps bodies that could not be read or decoded.
+	ErrInvalidUpstreamResponse = errors.New("invalid upstream response")
+)
+
+// maxErrorBodyLen bounds how much of a non-JSON error body is kept in UpstreamError.Msg.
+const maxErrorBodyLen = 256
+
+// UpstreamError is returned when Binance answers with a non-OK status. Code and Msg come
+// from the Binance error payload, e.g. {"code":-1121,"msg":"Invalid symbol."}.
+type UpstreamError struct {
+	// Upstream is the name of the service that made the call ("spot" or "futures").
+	Upstream string
+	// StatusCode is the HTTP status returned by Binance.
+	StatusCode int
+	// Code is the Binance error code, or 0 if the body was not a Binance error payload.
+	Code int
+	// Msg is the Binance error message, or the start of the raw body.
+	Msg string
+	// URL is the upstream request URL.
+	URL string
+	// RetryAfter is the wait requested by the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func newUpstreamError(upstream, requestURL string, resp *http.Response, body []byte) *UpstreamError {
+	e := &UpstreamError{
+		Upstream:   upstream,
+		StatusCode: resp.StatusCode,
+		URL:        requestURL,
+	}
+	var payload struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && (payload.Code != 0 || payload.Msg != "") {
+		e.Code, e.Msg = payload.Code, payload.Msg
+	} else {
+		e.Msg = strings.TrimSpace(string(body))
+		if len(e.Msg) > maxErrorBodyLen {
+			e.Msg = e.Msg[:maxErrorBodyLen]
+		}
+		if e.Msg == "" {
+			e.Msg = resp.Status
+		}
+	}
+	e.RetryAfter = parseRetryAfter(resp.Header)
+	return e
+}
+
+// parseRetryAfter returns the wait requested by a Retry-After header in seconds, or 0.
+func parseRetryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("binance %s returned status %d (code %d) for %s: %s", e.Upstream, e.StatusCode, e.Code, e.URL, e.Msg)
+}
+
+// IsRateLimited reports whether Binance rejected the call for exceeding a rate limit
+// (429) or because the IP is banned (418).
+func (e *UpstreamError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusTeapot
+}
+
+// IsClientError reports whether Binance rejected the request itself, e.g. an unknown
+// symbol or an invalid parameter, as opposed to failing to serve it.
+func (e *UpstreamError) IsClientError() bool {
+	if e.StatusCode < 400 || e.StatusCode >= 500 || e.IsRateLimited() {
+		return false
+	}
+	// -1000 to -1099 are server and network errors; request errors start at -1100.
+	// A 403 without a code comes from the WAF in front of the API, not from a bad request.
+	return e.Code <= -1100 || (e.Code == 0 && e.StatusCode != http.StatusForbidden)
+}
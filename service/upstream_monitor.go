@@ -0,0 +1,158 @@
+package service
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// monitorSamples bounds the number of recent call attempts kept per upstream.
+const monitorSamples = 2048
+
+// UpstreamMonitor records the outcome and latency of every upstream call attempt and
+// reports, per upstream, the error rate and latency percentiles of the recent attempts
+// and when the last success and error happened. Attempts that say nothing about the
+// upstream's health, such as bad requests, are left out like they are by the breakers.
+type UpstreamMonitor interface {
+	Status() map[string]UpstreamCallStatus
+
+	// record adds the outcome of one attempt.
+	record(upstream string, latency time.Duration, err error, outcome breakerOutcome)
+}
+
+// UpstreamCallStatus reports the recent calls to one upstream.
+type UpstreamCallStatus struct {
+	// Calls and Failures count every recorded attempt since startup.
+	Calls    uint64 `json:"calls"`
+	Failures uint64 `json:"failures"`
+	// RecentCalls, RecentFailures and ErrorRate cover the attempts within the window.
+	RecentCalls    int     `json:"recentCalls"`
+	RecentFailures int     `json:"recentFailures"`
+	ErrorRate      float64 `json:"errorRate"`
+	// Latency holds the round-trip percentiles of the attempts within the window.
+	Latency     LatencyPercentiles `json:"latency"`
+	LastSuccess *time.Time         `json:"lastSuccess,omitempty"`
+	LastError   *UpstreamCallError `json:"lastError,omitempty"`
+}
+
+// LatencyPercentiles summarizes round trips in milliseconds.
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50Ms"`
+	P90 float64 `json:"p90Ms"`
+	P99 float64 `json:"p99Ms"`
+	Max float64 `json:"maxMs"`
+}
+
+// UpstreamCallError is the last failed attempt of an upstream.
+type UpstreamCallError struct {
+	At      time.Time `json:"at"`
+	Message string    `json:"message"`
+}
+
+type callSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+type upstreamCalls struct {
+	samples     []callSample
+	next        int
+	calls       uint64
+	failures    uint64
+	lastSuccess time.Time
+	lastError   *UpstreamCallError
+}
+
+type upstreamMonitor struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	upstreams map[string]*upstreamCalls
+}
+
+// NewUpstreamMonitor creates and returns a new UpstreamMonitor that reports on the
+// attempts made within window.
+func NewUpstreamMonitor(window time.Duration) UpstreamMonitor {
+	return &upstreamMonitor{
+		window:    window,
+		upstreams: make(map[string]*upstreamCalls),
+	}
+}
+
+func (m *upstreamMonitor) Status() map[string]UpstreamCallStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	since := time.Now().Add(-m.window)
+	status := make(map[string]UpstreamCallStatus, len(m.upstreams))
+	for name, calls := range m.upstreams {
+		s := UpstreamCallStatus{
+			Calls:     calls.calls,
+			Failures:  calls.failures,
+			LastError: calls.lastError,
+		}
+		if !calls.lastSuccess.IsZero() {
+			lastSuccess := calls.lastSuccess
+			s.LastSuccess = &lastSuccess
+		}
+		var latencies []time.Duration
+		for _, sample := range calls.samples {
+			if sample.at.Before(since) {
+				continue
+			}
+			s.RecentCalls++
+			if sample.failed {
+				s.RecentFailures++
+			}
+			latencies = append(latencies, sample.latency)
+		}
+		if s.RecentCalls > 0 {
+			s.ErrorRate = float64(s.RecentFailures) / float64(s.RecentCalls)
+			slices.Sort(latencies)
+			s.Latency = LatencyPercentiles{
+				P50: milliseconds(percentile(latencies, 0.50)),
+				P90: milliseconds(percentile(latencies, 0.90)),
+				P99: milliseconds(percentile(latencies, 0.99)),
+				Max: milliseconds(latencies[len(latencies)-1]),
+			}
+		}
+		status[name] = s
+	}
+	return status
+}
+
+func (m *upstreamMonitor) record(upstream string, latency time.Duration, err error, outcome breakerOutcome) {
+	if outcome == outcomeIgnored {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls, ok := m.upstreams[upstream]
+	if !ok {
+		calls = &upstreamCalls{}
+		m.upstreams[upstream] = calls
+	}
+
+	now := time.Now()
+	sample := callSample{at: now, latency: latency, failed: outcome == outcomeFailure}
+	if len(calls.samples) < monitorSamples {
+		calls.samples = append(calls.samples, sample)
+	} else {
+		calls.samples[calls.next] = sample
+		calls.next = (calls.next + 1) % monitorSamples
+	}
+	calls.calls++
+	if sample.failed {
+		calls.failures++
+		calls.lastError = &UpstreamCallError{At: now, Message: err.Error()}
+	} else {
+		calls.lastSuccess = now
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted, which must not be empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ntdat104/go-crypto/config"
+)
+
+// fakeBinance is an upstream whose answer is set by the test. It counts the calls it
+// receives and when they arrive.
+type fakeBinance struct {
+	*httptest.Server
+	status atomic.Int32
+	// body, if set, replaces the ticker answered with status 200.
+	body atomic.Pointer[string]
+	// block, if set, holds every call until it is closed or the call is cancelled.
+	block atomic.Pointer[chan struct{}]
+	// received gets a value for every call that arrives.
+	received chan struct{}
+
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+func newFakeBinance(t *testing.T) *fakeBinance {
+	f := &fakeBinance{received: make(chan struct{}, 100)}
+	f.status.Store(http.StatusOK)
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.calls = append(f.calls, time.Now())
+		f.mu.Unlock()
+		f.received <- struct{}{}
+		if block := f.block.Load(); block != nil {
+			select {
+			case <-*block:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		status := int(f.status.Load())
+		w.WriteHeader(status)
+		if body := f.body.Load(); status == http.StatusOK && body != nil {
+			w.Write([]byte(*body))
+		} else if status == http.StatusOK {
+			w.Write([]byte(`{"symbol":"BTCUSDT","price":"65000.00"}`))
+		} else {
+			w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// drain forgets the calls received so far.
+func (f *fakeBinance) drain() {
+	for len(f.received) > 0 {
+		<-f.received
+	}
+}
+
+func (f *fakeBinance) callTimes() []time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Time(nil), f.calls...)
+}
+
+// newTestUpstream returns a spot upstream calling f with the breaker and retry settings
+// of cfg, and the registry holding its breaker.
+func newTestUpstream(t *testing.T, f *fakeBinance, cfg config.UpstreamConfig) (*upstream, CircuitBreakerRegistry) {
+	t.Helper()
+	defaults := config.Default()
+	cfg.BaseURL = f.URL
+	cache := newLocalCacheService(defaults.Cache)
+	refresher := NewBackgroundRefresher(1)
+	governor := NewRateLimitGovernor()
+	breakers := NewCircuitBreakerRegistry()
+	admin := NewCacheAdmin(cache, defaults.Cache.MaxEntries)
+	deps := UpstreamDependencies{
+		Cache:      cache,
+		Coalescer:  NewRequestCoalescer(),
+		Refresher:  refresher,
+		HTTPClient: NewHTTPClient(defaults.HTTP),
+		Governor:   governor,
+		Breakers:   breakers,
+		Admin:      admin,
+		Scheduler:  NewRefreshScheduler(config.WarmConfig{Tick: defaults.Warm.Tick, IdleTimeout: defaults.Warm.IdleTimeout}, cache, refresher, governor, defaults.Cache.MaxEntries),
+		Clock:      NewUpstreamClock(defaults.Clock),
+		Monitor:    NewUpstreamMonitor(time.Minute),
+		Metrics:    NewMetrics(cache, admin, refresher, governor),
+	}
+	return newUpstream("spot", spotWeight, deps, cfg), breakers
+}
+
+func testUpstreamConfig() config.UpstreamConfig {
+	cfg := config.Default().Spot
+	cfg.CircuitBreaker = config.CircuitBreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      config.Duration(50 * time.Millisecond),
+		HalfOpenMaxCalls: 1,
+	}
+	cfg.Retry = config.RetryConfig{MaxAttempts: 1}
+	return cfg
+}
+
+func fetchTicker(ctx context.Context, u *upstream, f *fakeBinance) error {
+	_, err := u.fetchData(ctx, cacheRequest{
+		cacheName: "tickerprice",
+		apiURL:    f.URL + "/api/v3/ticker/price",
+		params:    map[string]string{"symbol": "BTCUSDT"},
+	})
+	return err
+}
+
+func TestFetchDataBreakerOpensAndRecovers(t *testing.T) {
+	f := newFakeBinance(t)
+	u, breakers := newTestUpstream(t, f, testUpstreamConfig())
+	ctx := context.Background()
+
+	f.status.Store(http.StatusInternalServerError)
+	for i := 0; i < 2; i++ {
+		if err := fetchTicker(ctx, u, f); err == nil {
+			t.Fatal("expected an error from a failing upstream")
+		}
+	}
+	if state := breakers.States()["spot"].State; state != BreakerOpen {
+		t.Fatalf("state after 2 failures = %s, want %s", state, BreakerOpen)
+	}
+	if err := fetchTicker(ctx, u, f); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error while open = %v, want ErrCircuitOpen", err)
+	}
+	if calls := len(f.callTimes()); calls != 2 {
+		t.Fatalf("upstream calls = %d, want 2: an open breaker must not call the upstream", calls)
+	}
+
+	// Once the open timeout has passed one probe goes through, and other calls are
+	// refused while it is in flight.
+	time.Sleep(60 * time.Millisecond)
+	f.status.Store(http.StatusOK)
+	release := make(chan struct{})
+	f.block.Store(&release)
+	f.drain()
+	probe := make(chan error, 1)
+	go func() { probe <- fetchTicker(ctx, u, f) }()
+	<-f.received
+	if state := breakers.States()["spot"].State; state != BreakerHalfOpen {
+		t.Fatalf("state during the probe = %s, want %s", state, BreakerHalfOpen)
+	}
+	if err := fetchTicker(ctx, u, f); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error during the probe = %v, want ErrCircuitOpen", err)
+	}
+	close(release)
+	if err := <-probe; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if state := breakers.States()["spot"]; state.State != BreakerClosed || state.Opens != 1 {
+		t.Fatalf("state after the probe = %+v, want closed after 1 open", state)
+	}
+}
+
+func TestFetchDataRetriesTransientFailures(t *testing.T) {
+	f := newFakeBinance(t)
+	cfg := testUpstreamConfig()
+	cfg.CircuitBreaker.FailureThreshold = 10
+	cfg.Retry = config.RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   config.Duration(20 * time.Millisecond),
+		MaxDelay:    config.Duration(30 * time.Millisecond),
+	}
+	u, breakers := newTestUpstream(t, f, cfg)
+
+	f.status.Store(http.StatusBadGateway)
+	start := time.Now()
+	if err := fetchTicker(context.Background(), u, f); err == nil {
+		t.Fatal("expected an error from a failing upstream")
+	}
+	elapsed := time.Since(start)
+	calls := f.callTimes()
+	if len(calls) != 3 {
+		t.Fatalf("upstream calls = %d, want 3", len(calls))
+	}
+	if retries := breakers.States()["spot"].Retries; retries != 2 {
+		t.Fatalf("retries = %d, want 2", retries)
+	}
+	// The two waits are at most 20ms and 30ms (40ms capped by MaxDelay); allow for
+	// scheduling delays on top.
+	if elapsed > 50*time.Millisecond+time.Second {
+		t.Fatalf("retries took %s", elapsed)
+	}
+	for i := 1; i < len(calls); i++ {
+		if gap := calls[i].Sub(calls[i-1]); gap <= 0 {
+			t.Fatalf("retry %d did not wait: gap %s", i, gap)
+		}
+	}
+
+	f.status.Store(http.StatusBadRequest)
+	if err := fetchTicker(context.Background(), u, f); err == nil {
+		t.Fatal("expected an error for a bad request")
+	}
+	if calls := len(f.callTimes()); calls != 4 {
+		t.Fatalf("upstream calls = %d, want 4: client errors must not be retried", calls)
+	}
+}
+
+func TestRetryBackoffBounds(t *testing.T) {
+	policy := newRetryPolicy(config.RetryConfig{
+		MaxAttempts: 5,
+		BaseDelay:   config.Duration(100 * time.Millisecond),
+		MaxDelay:    config.Duration(300 * time.Millisecond),
+	})
+	ceilings := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, ceiling := range ceilings {
+		retry := i + 1
+		for n := 0; n < 1000; n++ {
+			if d := policy.backoff(retry); d <= 0 || d > ceiling {
+				t.Fatalf("backoff(%d) = %s, want within (0, %s]", retry, d, ceiling)
+			}
+		}
+	}
+	// Large retry counts must not overflow the shift into a negative or zero delay.
+	if d := policy.backoff(70); d <= 0 || d > 300*time.Millisecond {
+		t.Fatalf("backoff(70) = %s, want within (0, 300ms]", d)
+	}
+}
+
+func TestFetchDataClientErrorsDoNotTripBreaker(t *testing.T) {
+	f := newFakeBinance(t)
+	u, breakers := newTestUpstream(t, f, testUpstreamConfig())
+
+	f.status.Store(http.StatusBadRequest)
+	for i := 0; i < 5; i++ {
+		err := fetchTicker(context.Background(), u, f)
+		var upstreamErr *UpstreamError
+		if !errors.As(err, &upstreamErr) || !upstreamErr.IsClientError() {
+			t.Fatalf("error = %v, want a client UpstreamError", err)
+		}
+	}
+	if state := breakers.States()["spot"]; state.State != BreakerClosed || state.ConsecutiveFailures != 0 {
+		t.Fatalf("state after client errors = %+v, want closed without failures", state)
+	}
+}
+
+func TestFetchDataCancellationDoesNotTripBreaker(t *testing.T) {
+	f := newFakeBinance(t)
+	cfg := testUpstreamConfig()
+	cfg.Retry = config.RetryConfig{MaxAttempts: 3, BaseDelay: config.Duration(time.Millisecond), MaxDelay: config.Duration(time.Millisecond)}
+	u, breakers := newTestUpstream(t, f, cfg)
+
+	block := make(chan struct{})
+	defer close(block)
+	f.block.Store(&block)
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- fetchTicker(ctx, u, f) }()
+		<-f.received
+		cancel()
+		if err := <-done; err == nil {
+			t.Fatal("expected an error from a cancelled call")
+		}
+	}
+	if calls := len(f.callTimes()); calls != 5 {
+		t.Fatalf("upstream calls = %d, want 5: cancelled calls must not be retried", calls)
+	}
+	if state := breakers.States()["spot"]; state.State != BreakerClosed || state.ConsecutiveFailures != 0 {
+		t.Fatalf("state after cancelled calls = %+v, want closed without failures", state)
+	}
+}
+
+func TestTypedLookupRejectsBodyOfWrongShape(t *testing.T) {
+	f := newFakeBinance(t)
+	cfg := testUpstreamConfig()
+	cfg.CircuitBreaker.FailureThreshold = 10
+	u, breakers := newTestUpstream(t, f, cfg)
+	spot := &binanceSpotService{upstream: u, baseURL: f.URL}
+	key := u.cacheKey("alltickerprices", "global")
+
+	// An object where the all-symbol endpoint returns a list.
+	object := `{"symbol":"BTCUSDT","price":"65000.00"}`
+	f.body.Store(&object)
+	for i := 1; i <= 2; i++ {
+		if _, err := spot.GetAllTickerPrices(context.Background()); !errors.Is(err, ErrInvalidUpstreamResponse) {
+			t.Fatalf("error = %v, want ErrInvalidUpstreamResponse", err)
+		}
+		if u.cache.Has(key) {
+			t.Fatal("the body that failed to decode was cached")
+		}
+		if calls := len(f.callTimes()); calls != i {
+			t.Fatalf("upstream calls = %d, want %d", calls, i)
+		}
+	}
+	if failures := breakers.States()["spot"].ConsecutiveFailures; failures != 2 {
+		t.Fatalf("breaker failures = %d, want 2", failures)
+	}
+
+	// A good entry past its TTL is served rather than replaced by the bad body.
+	list := `[{"symbol":"BTCUSDT","price":"65000.00"}]`
+	past := time.Now().Add(-time.Minute)
+	u.cache.(*localCacheService).setEntry(key, &CacheEntry{Value: newRawResponse([]byte(list)), StoredAt: past, ExpireTime: past})
+	prices, err := spot.GetAllTickerPrices(context.Background())
+	if err != nil || len(prices) != 1 {
+		t.Fatalf("GetAllTickerPrices = %v, %v, want the stale entry", prices, err)
+	}
+}
+
+func TestPassthroughEntryDroppedWhenTypedDecodeFails(t *testing.T) {
+	f := newFakeBinance(t)
+	cfg := testUpstreamConfig()
+	cfg.CircuitBreaker.FailureThreshold = 10
+	u, breakers := newTestUpstream(t, f, cfg)
+	u.passthrough = true
+	spot := &binanceSpotService{upstream: u, baseURL: f.URL}
+	key := u.cacheKey("alltickerprices", "global")
+
+	object := `{"symbol":"BTCUSDT","price":"65000.00"}`
+	f.body.Store(&object)
+	// Pass-through requests serve the JSON body as it is, so it is cached.
+	ctx, passthrough := WithPassthrough(context.Background())
+	if _, err := spot.GetAllTickerPrices(ctx); err != nil {
+		t.Fatalf("pass-through lookup: %v", err)
+	}
+	if raw, ok := passthrough.Response(); !ok || string(raw.Body()) != object {
+		t.Fatal("pass-through lookup did not collect the body")
+	}
+
+	// A typed read cannot decode it, drops it and counts an upstream failure.
+	if _, err := spot.GetAllTickerPrices(context.Background()); !errors.Is(err, ErrInvalidUpstreamResponse) {
+		t.Fatalf("error = %v, want ErrInvalidUpstreamResponse", err)
+	}
+	if u.cache.Has(key) || u.cache.Has(key+delayKeySuffix) {
+		t.Fatal("the entry that failed to decode was kept")
+	}
+	if failures := breakers.States()["spot"].ConsecutiveFailures; failures != 1 {
+		t.Fatalf("breaker failures = %d, want 1", failures)
+	}
+	if status := u.monitor.Status()["spot"]; status.Failures != 1 {
+		t.Fatalf("monitor failures = %d, want 1", status.Failures)
+	}
+
+	list := fmt.Sprintf("[%s]", object)
+	f.body.Store(&list)
+	prices, err := spot.GetAllTickerPrices(context.Background())
+	if err != nil || len(prices) != 1 {
+		t.Fatalf("GetAllTickerPrices = %v, %v, want a fresh fetch", prices, err)
+	}
+	if calls := len(f.callTimes()); calls != 2 {
+		t.Fatalf("upstream calls = %d, want 2", calls)
+	}
+}
+
+func TestContainedLookupFallsBackToStaleLargerLimit(t *testing.T) {
+	f := newFakeBinance(t)
+	u, _ := newTestUpstream(t, f, testUpstreamConfig())
+	u.limits = newLimitIndex(config.Default().Cache.Containment)
+	spot := &binanceSpotService{upstream: u, baseURL: f.URL}
+
+	depth := `{"lastUpdateId":1,"bids":[["3","1"],["2","1"],["1","1"]],"asks":[["4","1"],["5","1"],["6","1"]]}`
+	f.body.Store(&depth)
+	if _, err := spot.GetDepth(context.Background(), "BTCUSDT", 100); err != nil {
+		t.Fatalf("GetDepth at 100: %v", err)
+	}
+	// The larger response expires while the upstream fails.
+	key := u.cacheKey("depth", "BTCUSDT-100")
+	entry, _ := u.cache.GetEntry(key)
+	past := time.Now().Add(-time.Minute)
+	u.cache.(*localCacheService).setEntry(key, &CacheEntry{Value: entry.Value, StoredAt: past, ExpireTime: past})
+	f.status.Store(http.StatusInternalServerError)
+
+	ctx, result := WithCacheResult(context.Background())
+	sliced, err := spot.GetDepth(ctx, "BTCUSDT", 2)
+	if err != nil {
+		t.Fatalf("GetDepth at 2: %v, want the stale larger response", err)
+	}
+	if len(sliced.Bids) != 2 || len(sliced.Asks) != 2 {
+		t.Fatalf("depth = %+v, want 2 levels per side", sliced)
+	}
+	if status, _ := result.Status(); status != CacheStale {
+		t.Fatalf("cache status = %s, want %s", status, CacheStale)
+	}
+}